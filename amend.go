@@ -0,0 +1,68 @@
+package main
+
+type AmendResult uint8
+
+const (
+	AmendRejected  AmendResult = iota
+	AmendedInPlace             // quantity reduced, queue position kept
+	AmendRequeued              // price change or quantity increase, back of the queue
+)
+
+// Amend modifies a resting order's price and open quantity (displayed plus
+// any iceberg reserve).
+//   - Same price and qty <= open qty: reduced in place, FIFO position kept.
+//   - Otherwise the order loses priority: it is pulled from its level, stamped
+//     with 'seq' and re-placed at the back of the (possibly new) level.
+//     A price that now crosses the book matches first, like a new order.
+//   - Pending stops stay in the trigger book; 'newPrice' is their limit price.
+//   - During an auction call nothing matches; the order just requeues.
+func (b *OrderBook) Amend(id uint64, newPrice, newQty int64, seq uint64, rq *retireRing) (AmendResult, RejectReason) {
+	if !b.acceptsAmends() {
+		return AmendRejected, RejectSessionState
+	}
+	b.ExpireOrders(rq)
+	o, r := b.Lookup(id)
+	if r != RejectNone {
+		return AmendRejected, r
+	}
+	if r := b.validate(o.Type, newPrice, newQty); r != RejectNone {
+		return AmendRejected, r
+	}
+	if isPostOnly(o.Type) {
+		p, r := b.postOnlyPrice(o.Type, o.Side, newPrice)
+		if r != RejectNone {
+			return AmendRejected, r
+		}
+		newPrice = p
+	}
+	if !b.retireReady(rq) {
+		return AmendRejected, RejectRetireRingFull
+	}
+	b.LastSeq.Store(seq)
+
+	if newPrice == o.Price && newQty <= o.Qty+o.hidden {
+		if lvl := b.levelOf(o); lvl != nil {
+			b.reduce(lvl, o, newQty)
+			if !isStop(o.Type) {
+				b.emitL2(o.Side, lvl.Price, lvl, L2Change)
+			}
+		}
+		return AmendedInPlace, RejectNone
+	}
+
+	b.unlink(o.Price, o, o.Side)
+	o.Price, o.Qty, o.SeqID = newPrice, newQty, seq
+	o.hidden = 0 // icebergs re-split on enqueue
+
+	killed := false
+	if !isStop(o.Type) && !b.calling() {
+		_, killed = b.match(o, rq)
+	}
+	if o.Qty == 0 || killed {
+		b.retire(o, rq)
+	} else {
+		b.enqueue(o)
+	}
+	b.releaseStops(rq)
+	return AmendRequeued, RejectNone
+}
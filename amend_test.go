@@ -0,0 +1,117 @@
+package main
+
+import "testing"
+
+func TestAmendReduceKeepsPriority(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	o1, _ := book.placeOrder(Bid, Limit, 100, 1, 10, 1, pool, rq)
+	o2, _ := book.placeOrder(Bid, Limit, 100, 2, 10, 2, pool, rq)
+
+	res, r := book.Amend(1, 100, 4, 3, rq)
+	if res != AmendedInPlace || r != RejectNone {
+		t.Fatalf("expected in-place amend, got %v %v", res, r)
+	}
+	lvl := book.Bids.FindLevel(100)
+	if lvl.head != o1 || lvl.tail != o2 {
+		t.Error("reduction must keep FIFO position")
+	}
+	if o1.Qty != 4 || lvl.TotalQty != 14 {
+		t.Errorf("expected qty=4 total=14, got qty=%d total=%d", o1.Qty, lvl.TotalQty)
+	}
+	if o1.SeqID != 1 {
+		t.Errorf("in-place amend must keep SeqID, got %d", o1.SeqID)
+	}
+}
+
+func TestAmendIncreaseRequeues(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	o1, _ := book.placeOrder(Bid, Limit, 100, 1, 10, 1, pool, rq)
+	o2, _ := book.placeOrder(Bid, Limit, 100, 2, 10, 2, pool, rq)
+
+	res, _ := book.Amend(1, 100, 15, 3, rq)
+	if res != AmendRequeued {
+		t.Fatalf("expected requeue on increase, got %v", res)
+	}
+	lvl := book.Bids.FindLevel(100)
+	if lvl.head != o2 || lvl.tail != o1 {
+		t.Error("increase must move order to back of the queue")
+	}
+	if lvl.TotalQty != 25 || o1.SeqID != 3 {
+		t.Errorf("expected total=25 seq=3, got total=%d seq=%d", lvl.TotalQty, o1.SeqID)
+	}
+}
+
+func TestAmendPriceChangeMovesLevel(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	o1, _ := book.placeOrder(Ask, Limit, 105, 1, 10, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 104, 2, 10, 2, pool, rq)
+
+	res, _ := book.Amend(1, 104, 10, 3, rq)
+	if res != AmendRequeued {
+		t.Fatalf("expected requeue on price change, got %v", res)
+	}
+	if book.Asks.FindLevel(105) != nil {
+		t.Error("expected old level removed once empty")
+	}
+	lvl := book.Asks.FindLevel(104)
+	if lvl == nil || lvl.tail != o1 || lvl.TotalQty != 20 {
+		t.Error("expected order at back of level 104")
+	}
+}
+
+func TestAmendCrossingPriceMatches(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	rec := &execRecorder{}
+	book.SetExecutionSink(rec)
+	_, _ = book.placeOrder(Ask, Limit, 101, 1, 4, 1, pool, rq)
+	bid, _ := book.placeOrder(Bid, Limit, 99, 2, 10, 2, pool, rq)
+
+	res, _ := book.Amend(2, 101, 10, 3, rq)
+	if res != AmendRequeued {
+		t.Fatalf("expected requeue, got %v", res)
+	}
+	if len(rec.execs) != 1 || rec.execs[0].Qty != 4 || rec.execs[0].Price != 101 {
+		t.Fatalf("expected one fill of 4 @101, got %+v", rec.execs)
+	}
+	if bid.Qty != 6 || book.Bids.FindLevel(101) == nil || book.Bids.FindLevel(99) != nil {
+		t.Error("expected remainder resting at the new price")
+	}
+	if book.Asks.Size() != 0 {
+		t.Error("expected ask side emptied")
+	}
+}
+
+func TestAmendRejects(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Bid, Limit, 100, 1, 10, 1, pool, rq)
+
+	if res, r := book.Amend(9, 100, 5, 2, rq); res != AmendRejected || r != RejectUnknownOrder {
+		t.Errorf("expected unknown order reject, got %v %v", res, r)
+	}
+	if res, r := book.Amend(1, 100, 0, 2, rq); res != AmendRejected || r != RejectInvalidQty {
+		t.Errorf("expected invalid qty reject, got %v %v", res, r)
+	}
+	_ = book.CancelByID(1, rq)
+	if res, r := book.Amend(1, 100, 5, 3, rq); res != AmendRejected || r != RejectAlreadyDone {
+		t.Errorf("expected already done reject, got %v %v", res, r)
+	}
+}
+
+func TestAmendPendingStop(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	s, _ := placeStop(book, Bid, StopLimit, 110, 111, 1, 10, pool, rq)
+
+	if res, _ := book.Amend(1, 111, 4, 2, rq); res != AmendedInPlace {
+		t.Errorf("expected in-place reduce of pending stop, got %v", res)
+	}
+	if res, _ := book.Amend(1, 112, 4, 3, rq); res != AmendRequeued || s.Price != 112 {
+		t.Errorf("expected limit price change to requeue, got %v price=%d", res, s.Price)
+	}
+	lvl := book.buyStops.FindLevel(110)
+	if lvl == nil || lvl.head != s || lvl.TotalQty != 4 {
+		t.Error("expected amended stop to stay in the trigger book")
+	}
+	if book.Bids.Size() != 0 {
+		t.Error("amended stop must not reach the visible book")
+	}
+}
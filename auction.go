@@ -0,0 +1,178 @@
+package main
+
+// ---------------- Call auction ---------------- //
+//
+// During a call phase (any session state but Continuous, see session.go)
+// orders accumulate in the book without matching, so it may be crossed. The equilibrium price
+// is the limit price in the crossed range that
+//   1. maximises executable volume, then
+//   2. minimises the imbalance (surplus on one side at that price), then
+//   3. lies closest to the reference price (last trade unless set), then
+//   4. is the lowest.
+// Hidden iceberg reserve takes part. At uncross every eligible order trades
+// at that single price, bids and asks each in price-time priority, and
+// continuous matching resumes. Only orders that can rest join the call;
+// stops wait in the trigger book and fire on the uncross price.
+// Self-trade prevention applies to continuous matching only.
+
+// AuctionResult is an indicative or executed uncross.
+type AuctionResult struct {
+	Price     int64 // equilibrium price (0 = book not crossed)
+	Volume    int64 // quantity executable at Price
+	Imbalance int64 // bid minus ask quantity at Price left unmatched
+}
+
+type auctionLevel struct {
+	price int64
+	qty   int64 // displayed plus hidden
+}
+
+// auctionState is the uncross scratch space (matcher only).
+type auctionState struct {
+	ref  int64 // reference price for tiebreaks (0 = last trade)
+	asks []auctionLevel
+	bids []auctionLevel // ascending, like asks
+}
+
+// StartAuction calls an unscheduled auction, moving a Continuous book into
+// VolatilityAuction. No-op in an auction already; ErrSessionTransition when
+// pre-open, halted or closed.
+func (b *OrderBook) StartAuction() error {
+	switch b.session {
+	case OpeningAuction, ClosingAuction, VolatilityAuction:
+		return nil
+	}
+	_, err := b.SetSession(VolatilityAuction, nil) // entering a call executes nothing
+	return err
+}
+
+// InAuction reports whether matching is suspended (see calling).
+func (b *OrderBook) InAuction() bool { return b.calling() }
+
+// SetReferencePrice sets the auction tiebreak price (0 = last trade) and
+// the static band reference (0 = none until the next uncross).
+func (b *OrderBook) SetReferencePrice(p int64) { b.auction.ref, b.bands.ref = p, p }
+
+// joinsCall reports whether orders of type t are accepted during a call.
+func joinsCall(t OrderType) bool { return t == Limit || isStop(t) }
+
+// IndicativeAuction returns the uncross the book would do now.
+func (b *OrderBook) IndicativeAuction() AuctionResult {
+	bid, ask := b.Bids.MaxLevel(), b.Asks.MinLevel()
+	if bid == nil || ask == nil || bid.Price < ask.Price {
+		return AuctionResult{}
+	}
+	a := &b.auction
+	// Only levels inside the crossed range [best ask, best bid] matter.
+	a.asks = a.asks[:0]
+	for lvl := ask; lvl != nil && lvl.Price <= bid.Price; lvl = b.Asks.Successor(lvl.Price) {
+		a.asks = append(a.asks, auctionLevel{lvl.Price, lvl.TotalQty + lvl.HiddenQty})
+	}
+	a.bids = a.bids[:0]
+	var bidTotal int64
+	for lvl := b.Bids.FindLevel(bid.Price); lvl != nil && lvl.Price >= ask.Price; lvl = b.Bids.Predecessor(lvl.Price) {
+		a.bids = append(a.bids, auctionLevel{lvl.Price, lvl.TotalQty + lvl.HiddenQty})
+		bidTotal += lvl.TotalQty + lvl.HiddenQty
+	}
+	for i, j := 0, len(a.bids)-1; i < j; i, j = i+1, j-1 {
+		a.bids[i], a.bids[j] = a.bids[j], a.bids[i]
+	}
+
+	ref := a.ref
+	if ref == 0 {
+		ref = b.lastTrade
+	}
+	// Walk candidate prices upwards: asks at or below the price keep
+	// adding up, bids below it drop out.
+	var best AuctionResult
+	var askCum, bidBelow int64
+	i, j := 0, 0
+	for i < len(a.asks) || j < len(a.bids) {
+		p := int64(0)
+		switch {
+		case j == len(a.bids) || (i < len(a.asks) && a.asks[i].price <= a.bids[j].price):
+			p = a.asks[i].price
+		default:
+			p = a.bids[j].price
+		}
+		for i < len(a.asks) && a.asks[i].price == p {
+			askCum += a.asks[i].qty
+			i++
+		}
+		bidCum := bidTotal - bidBelow
+		for j < len(a.bids) && a.bids[j].price == p {
+			bidBelow += a.bids[j].qty
+			j++
+		}
+		c := AuctionResult{Price: p, Volume: min(askCum, bidCum), Imbalance: bidCum - askCum}
+		if c.Volume > 0 && betterUncross(c, best, ref) {
+			best = c
+		}
+	}
+	return best
+}
+
+// betterUncross reports whether candidate c beats the best so far
+// (candidates come in ascending price order).
+func betterUncross(c, best AuctionResult, ref int64) bool {
+	switch {
+	case best.Volume == 0 || c.Volume != best.Volume:
+		return c.Volume > best.Volume
+	case abs64(c.Imbalance) != abs64(best.Imbalance):
+		return abs64(c.Imbalance) < abs64(best.Imbalance)
+	case ref != 0:
+		return abs64(c.Price-ref) < abs64(best.Price-ref)
+	}
+	return false // lower price wins
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Uncross ends the running auction through SetSession: opening and
+// volatility auctions go to Continuous (firing any stops the uncross price
+// triggered), the closing auction to Closed. Returns what was executed.
+// Any other state is ErrSessionTransition and nothing trades: a halted or
+// closed book never uncrosses here.
+func (b *OrderBook) Uncross(rq *retireRing) (AuctionResult, error) {
+	switch b.session {
+	case OpeningAuction, VolatilityAuction:
+		return b.SetSession(Continuous, rq)
+	case ClosingAuction:
+		return b.SetSession(Closed, rq)
+	}
+	return AuctionResult{}, ErrSessionTransition
+}
+
+// uncross executes the equilibrium volume, leaving the session as it is.
+func (b *OrderBook) uncross(rq *retireRing) AuctionResult {
+	res := b.IndicativeAuction()
+	left := res.Volume
+	for left > 0 {
+		bidLvl, askLvl := b.Bids.MaxLevel(), b.Asks.MinLevel()
+		if bidLvl == nil || askLvl == nil || bidLvl.Price < res.Price || askLvl.Price > res.Price {
+			break // cannot happen: the volume was counted from these levels
+		}
+		bid, ask := bidLvl.head, askLvl.head
+		trade := min(min(bid.Qty, ask.Qty), left)
+		bid.Qty -= trade
+		ask.Qty -= trade
+		bid.Filled += trade
+		ask.Filled += trade
+		bidLvl.TotalQty -= trade
+		askLvl.TotalQty -= trade
+		left -= trade
+		b.lastTrade = res.Price
+		b.emitAuctionExecution(bid, ask, res.Price, trade)
+		b.settle(bidLvl, bid, trade, rq)
+		b.settle(askLvl, ask, trade, rq)
+	}
+	if res.Volume > 0 {
+		b.bands.ref = res.Price
+	}
+	return res
+}
@@ -0,0 +1,144 @@
+package main
+
+import "testing"
+
+// callBook starts a call and places limit orders {side, price, qty}.
+func callBook(t *testing.T, orders ...[3]int64) (*OrderBook, *OrderPool, *retireRing) {
+	t.Helper()
+	book, pool, rq := newTestEnv()
+	if err := book.StartAuction(); err != nil {
+		t.Fatal(err)
+	}
+	for i, o := range orders {
+		id := uint64(i + 1)
+		if _, r := book.placeOrder(Side(o[0]), Limit, o[1], id, o[2], id, pool, rq); r != RejectNone {
+			t.Fatalf("order %d rejected: %v", id, r)
+		}
+	}
+	return book, pool, rq
+}
+
+func TestAuctionCallAccumulatesWithoutMatching(t *testing.T) {
+	book, pool, rq := callBook(t, [3]int64{int64(Bid), 102, 10}, [3]int64{int64(Ask), 99, 10})
+	var execs int
+	book.SetExecutionSink(ExecutionSinkFunc(func(Execution) { execs++ }))
+
+	if execs != 0 || book.Bids.MaxLevel().Price != 102 || book.Asks.MinLevel().Price != 99 {
+		t.Fatal("expected a crossed book with no trades during the call")
+	}
+	for _, typ := range []OrderType{Market, IOC, FOK, PostOnly} {
+		if _, r := book.placeOrder(Bid, typ, 105, 50, 1, 50, pool, rq); r != RejectAuctionCall {
+			t.Errorf("type %d: expected auction call reject, got %v", typ, r)
+		}
+	}
+	if _, r := book.Amend(1, 104, 10, 60, rq); r != RejectNone || execs != 0 {
+		t.Errorf("amend during the call must requeue without trading (%v, %d execs)", r, execs)
+	}
+}
+
+func TestEquilibriumMaximisesVolume(t *testing.T) {
+	book, _, _ := callBook(t,
+		[3]int64{int64(Bid), 102, 10}, [3]int64{int64(Bid), 101, 20}, [3]int64{int64(Bid), 100, 30},
+		[3]int64{int64(Ask), 99, 15}, [3]int64{int64(Ask), 100, 25}, [3]int64{int64(Ask), 101, 20},
+	)
+	got := book.IndicativeAuction()
+	if want := (AuctionResult{Price: 100, Volume: 40, Imbalance: 20}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestEquilibriumMinimisesImbalance(t *testing.T) {
+	book, _, _ := callBook(t,
+		[3]int64{int64(Bid), 101, 10},
+		[3]int64{int64(Ask), 99, 10}, [3]int64{int64(Ask), 100, 5},
+	)
+	got := book.IndicativeAuction()
+	if want := (AuctionResult{Price: 99, Volume: 10, Imbalance: 0}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestEquilibriumReferenceTiebreak(t *testing.T) {
+	for _, c := range []struct{ ref, want int64 }{{0, 99}, {101, 101}, {120, 101}, {100, 99}, {90, 99}} {
+		book, _, _ := callBook(t, [3]int64{int64(Bid), 101, 10}, [3]int64{int64(Ask), 99, 10})
+		book.SetReferencePrice(c.ref)
+		if got := book.IndicativeAuction(); got.Price != c.want || got.Volume != 10 {
+			t.Errorf("ref %d: got %+v, want price %d", c.ref, got, c.want)
+		}
+	}
+}
+
+func TestEquilibriumUncrossedBook(t *testing.T) {
+	book, _, _ := callBook(t, [3]int64{int64(Bid), 99, 10}, [3]int64{int64(Ask), 100, 10})
+	if got := book.IndicativeAuction(); got != (AuctionResult{}) {
+		t.Errorf("expected no uncross, got %+v", got)
+	}
+}
+
+func TestUncrossAtSinglePrice(t *testing.T) {
+	book, pool, rq := callBook(t,
+		[3]int64{int64(Bid), 102, 10}, [3]int64{int64(Bid), 101, 20}, [3]int64{int64(Bid), 100, 30},
+		[3]int64{int64(Ask), 99, 15}, [3]int64{int64(Ask), 100, 25}, [3]int64{int64(Ask), 101, 20},
+	)
+	var execs []Execution
+	book.SetExecutionSink(ExecutionSinkFunc(func(e Execution) { execs = append(execs, e) }))
+
+	res, err := book.Uncross(rq)
+	if err != nil || res.Price != 100 || res.Volume != 40 || book.InAuction() || book.Session() != Continuous {
+		t.Fatalf("unexpected uncross %+v", res)
+	}
+	var vol int64
+	for _, e := range execs {
+		if e.Price != 100 || !e.Auction {
+			t.Errorf("fill %+v not at the uncross price", e)
+		}
+		vol += e.Qty
+	}
+	if vol != 40 {
+		t.Errorf("executed %d, want 40", vol)
+	}
+	// Bids fill best price first; the 100 bid keeps 20, the 101 ask all 20.
+	want := []l3Row{{3, 100, 20}, {6, 101, 20}}
+	got := l3Rows(book)
+	if len(got) != len(want) {
+		t.Fatalf("book after uncross = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// Continuous trading resumes.
+	if _, r := book.placeOrder(Ask, IOC, 100, 20, 5, 20, pool, rq); r != RejectNone || len(execs) == 0 || execs[len(execs)-1].Auction {
+		t.Errorf("expected a continuous fill after the uncross (%v)", r)
+	}
+}
+
+func TestUncrossIcebergReserveAndStops(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_ = book.StartAuction()
+	_, _ = book.submit(OrderSpec{ID: 1, Seq: 1, Side: Ask, Type: Limit, Price: 100, Qty: 30, Display: 10}, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 103, 2, 10, 2, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 100, 3, 25, 3, pool, rq)
+	_, _ = book.submit(OrderSpec{ID: 4, Seq: 4, Side: Bid, Type: Stop, StopPrice: 100, Qty: 8}, pool, rq)
+
+	if got := book.IndicativeAuction(); got.Volume != 25 || got.Price != 100 {
+		t.Fatalf("hidden reserve must count: %+v", got)
+	}
+	if book.buyStops.Size() != 1 {
+		t.Fatal("stop fired during the call")
+	}
+	_, _ = book.Uncross(rq)
+	// 25 of the iceberg traded in the auction, the stop took the last 5
+	// and 3 more from the 103 ask.
+	if _, r := book.Lookup(1); r != RejectAlreadyDone {
+		t.Errorf("expected the iceberg exhausted, got %v", r)
+	}
+	if book.buyStops.Size() != 0 || book.lastTrade != 103 {
+		t.Errorf("expected the stop fired after the uncross, last trade %d", book.lastTrade)
+	}
+	if lvl := book.Asks.MinLevel(); lvl == nil || lvl.TotalQty != 7 {
+		t.Errorf("expected 7 left at 103, got %+v", lvl)
+	}
+}
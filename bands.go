@@ -0,0 +1,133 @@
+package main
+
+import "math"
+
+// ---------------- Price bands ---------------- //
+//
+// Continuous matching only executes inside the price band:
+//   - static: within Static ticks of the static reference, which is the
+//     last uncross price (or SetReferencePrice) and holds between auctions
+//   - dynamic: within Dynamic ticks of the last trade when the aggressor
+//     arrives, so a sweep cannot walk the band along with it
+// The band is the intersection of whichever are set; a band with no price
+// to anchor to (no reference, no trade yet) is open. An aggressor that
+// reaches a level outside the band stops there and the breach is reported,
+// then Action decides what happens:
+//   - BandReject: the aggressor's remainder is cancelled, trading goes on
+//   - BandAuction: the book enters a volatility auction; a limit remainder
+//     joins it, anything else is cancelled
+//   - BandHalt: the book halts, same remainder rules as the auction
+// FOK orders are checked against in-band liquidity only and
+// killed without a breach. Auction uncrosses are not banded.
+
+type BandAction uint8
+
+const (
+	BandReject  BandAction = iota // cancel the aggressor's remainder
+	BandAuction                   // move to VolatilityAuction
+	BandHalt                      // move to Halted
+)
+
+func (a BandAction) String() string {
+	switch a {
+	case BandReject:
+		return "reject"
+	case BandAuction:
+		return "volatility auction"
+	case BandHalt:
+		return "halt"
+	}
+	return "unknown"
+}
+
+// PriceBands configures the execution band (zero value: no bands).
+type PriceBands struct {
+	Static  int64 // ticks either side of the static reference (0 = off)
+	Dynamic int64 // ticks either side of the last trade (0 = off)
+	Action  BandAction
+}
+
+// BandBreach reports an aggressor stopped at the edge of the band.
+type BandBreach struct {
+	Seq       uint64 // per book, from 1
+	OrderID   uint64
+	Side      Side
+	Price     int64 // best opposite price, outside the band
+	Low, High int64 // band in force (0 = open on that side)
+	Filled    int64 // aggressor's quantity executed in band before the stop
+	Remainder int64 // open quantity left when it stopped
+	Action    BandAction
+	Time      int64 // book clock
+}
+
+// BandSink receives band breaches on the matcher thread.
+type BandSink interface {
+	OnBandBreach(e BandBreach)
+}
+
+// BandSinkFunc adapts a plain function to BandSink.
+type BandSinkFunc func(e BandBreach)
+
+func (f BandSinkFunc) OnBandBreach(e BandBreach) { f(e) }
+
+// bandState is the configured bands and their static anchor.
+type bandState struct {
+	PriceBands
+	ref  int64 // static reference (0 = none yet)
+	seq  uint64
+	sink BandSink
+}
+
+// SetPriceBands configures the execution band and its breach reports
+// (nil sink disables reporting).
+func (b *OrderBook) SetPriceBands(pb PriceBands, s BandSink) {
+	b.bands.PriceBands, b.bands.sink = pb, s
+}
+
+// bandRange returns the prices continuous matching may trade at now.
+func (b *OrderBook) bandRange() (lo, hi int64) {
+	lo, hi = 0, math.MaxInt64
+	narrow := func(anchor, ticks int64) {
+		if ticks <= 0 || anchor <= 0 {
+			return
+		}
+		d := ticks * b.inst.TickSize
+		if anchor-d > lo {
+			lo = anchor - d
+		}
+		hi = min(hi, anchor+d)
+	}
+	narrow(b.bands.ref, b.bands.Static)
+	narrow(b.lastTrade, b.bands.Dynamic)
+	return lo, hi
+}
+
+// breach reports aggressor o stopped at 'price' outside [lo, hi] and
+// applies the band action. Anything but a move into a call cancels the
+// remainder: breach reports it, and the caller retires o with the
+// cancelled quantity left in o.Qty.
+func (b *OrderBook) breach(o *Order, price, lo, hi, filled int64, rq *retireRing) bool {
+	action := b.bands.Action
+	var to SessionState
+	switch action {
+	case BandAuction:
+		to = VolatilityAuction
+	case BandHalt:
+		to = Halted
+	}
+	if action != BandReject {
+		_, _ = b.SetSession(to, rq) // always allowed: matching only runs in Continuous
+	}
+	b.bands.seq++
+	e := BandBreach{
+		Seq: b.bands.seq, OrderID: o.ID, Side: o.Side, Price: price, Low: lo, Filled: filled,
+		Remainder: o.Qty, Action: action, Time: b.clock.Now(),
+	}
+	if hi != math.MaxInt64 {
+		e.High = hi
+	}
+	if b.bands.sink != nil {
+		b.bands.sink.OnBandBreach(e)
+	}
+	return action == BandReject || o.Type != Limit
+}
@@ -0,0 +1,128 @@
+package main
+
+import "testing"
+
+// bandedBook has asks at each price (qty 10) and a last trade at 100.
+func bandedBook(asks ...int64) (*OrderBook, *OrderPool, *retireRing) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 1, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, IOC, 100, 2, 1, 2, pool, rq)
+	for i, p := range asks {
+		id := uint64(10 + i)
+		_, _ = book.placeOrder(Ask, Limit, p, id, 10, id, pool, rq)
+	}
+	return book, pool, rq
+}
+
+func TestDynamicBandStopsSweep(t *testing.T) {
+	book, pool, rq := bandedBook(101, 102, 103, 110)
+	book.SetClock(NewManualClock(7))
+	var got []BandBreach
+	book.SetPriceBands(PriceBands{Dynamic: 2}, BandSinkFunc(func(e BandBreach) { got = append(got, e) }))
+
+	o, r := book.placeOrder(Bid, Market, 0, 50, 100, 50, pool, rq)
+	if r != RejectNone {
+		t.Fatal(r)
+	}
+	// Anchored at arrival: trading at 102 does not open 103 and 104. The
+	// cancelled remainder stays in Qty, as for any IOC leftover.
+	if o.Filled != 20 || o.Qty != 80 {
+		t.Errorf("filled %d left %d, want 20 and 80", o.Filled, o.Qty)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected one breach, got %+v", got)
+	}
+	want := BandBreach{Seq: 1, OrderID: 50, Side: Bid, Price: 103, Low: 98, High: 102, Filled: 20, Remainder: 80, Action: BandReject, Time: 7}
+	if got[0] != want {
+		t.Errorf("breach = %+v, want %+v", got[0], want)
+	}
+	if lvl := book.Asks.MinLevel(); lvl == nil || lvl.Price != 103 || book.Session() != Continuous {
+		t.Error("book beyond the band or session changed")
+	}
+	if _, r := book.Lookup(50); r == RejectNone {
+		t.Error("rejected remainder still live")
+	}
+}
+
+func TestStaticBandTriggersVolatilityAuction(t *testing.T) {
+	book, pool, rq := bandedBook(103, 104, 108)
+	book.SetReferencePrice(100)
+	book.SetPriceBands(PriceBands{Static: 5, Action: BandAuction}, nil)
+	var sessions []SessionEvent
+	book.SetSessionSink(SessionSinkFunc(func(e SessionEvent) { sessions = append(sessions, e) }))
+
+	o, _ := book.placeOrder(Bid, Limit, 120, 50, 30, 50, pool, rq)
+	if o.Filled != 20 || book.Session() != VolatilityAuction || !book.InAuction() {
+		t.Fatalf("filled %d in %v, want 20 in a volatility auction", o.Filled, book.Session())
+	}
+	if len(sessions) != 1 || sessions[0].To != VolatilityAuction {
+		t.Errorf("session events %+v", sessions)
+	}
+	if lvl := book.Bids.MaxLevel(); lvl == nil || lvl.Price != 120 || lvl.TotalQty != 10 {
+		t.Error("limit remainder did not join the call")
+	}
+	if _, r := book.placeOrder(Bid, Market, 0, 51, 1, 51, pool, rq); r != RejectAuctionCall {
+		t.Errorf("market order during volatility auction: %v", r)
+	}
+
+	res, err := book.SetSession(Continuous, rq)
+	if err != nil || res.Price != 108 || res.Volume != 10 {
+		t.Fatalf("uncross %+v, %v", res, err)
+	}
+	if lo, hi := book.bandRange(); lo != 103 || hi != 113 {
+		t.Errorf("static band not re-anchored at the uncross: [%d, %d]", lo, hi)
+	}
+}
+
+func TestBandHaltCancelsMarketRemainder(t *testing.T) {
+	book, pool, rq := bandedBook(101, 105)
+	book.SetPriceBands(PriceBands{Dynamic: 3, Action: BandHalt}, nil)
+
+	o, _ := book.placeOrder(Bid, Market, 0, 50, 15, 50, pool, rq)
+	if o.Filled != 10 || o.Qty != 5 || book.Session() != Halted {
+		t.Fatalf("filled %d left %d in %v, want 10, 5 and halted", o.Filled, o.Qty, book.Session())
+	}
+	if _, r := book.Lookup(50); r == RejectNone {
+		t.Error("market remainder rests in a halted book")
+	}
+	if _, r := book.placeOrder(Bid, Limit, 101, 51, 1, 51, pool, rq); r != RejectSessionState {
+		t.Errorf("order accepted while halted: %v", r)
+	}
+}
+
+func TestFOKChecksInBandLiquidityOnly(t *testing.T) {
+	book, pool, rq := bandedBook(101, 105)
+	var breaches int
+	book.SetPriceBands(PriceBands{Dynamic: 2}, BandSinkFunc(func(BandBreach) { breaches++ }))
+
+	o, _ := book.placeOrder(Bid, FOK, 105, 50, 15, 50, pool, rq)
+	if o.Filled != 0 || breaches != 0 {
+		t.Errorf("FOK filled %d with %d breaches, want a silent kill", o.Filled, breaches)
+	}
+	if o, _ = book.placeOrder(Bid, FOK, 105, 51, 10, 51, pool, rq); o.Filled != 10 {
+		t.Errorf("in-band FOK filled %d", o.Filled)
+	}
+}
+
+// The band has two edges: an ask below it is out of reach for a buy too.
+func TestFOKOutsideLowEdgeKilledWithoutAuction(t *testing.T) {
+	book, pool, rq := bandedBook(101)
+	_, _ = book.placeOrder(Ask, Limit, 90, 20, 10, 20, pool, rq)
+	var breaches int
+	book.SetPriceBands(PriceBands{Dynamic: 2, Action: BandAuction}, BandSinkFunc(func(BandBreach) { breaches++ }))
+
+	o, _ := book.placeOrder(Bid, FOK, 101, 50, 10, 50, pool, rq)
+	if o.Filled != 0 || breaches != 0 || book.Session() != Continuous {
+		t.Errorf("FOK filled %d, %d breaches, session %v; want a silent kill", o.Filled, breaches, book.Session())
+	}
+	if lvl := book.Asks.MinLevel(); lvl == nil || lvl.Price != 90 || lvl.TotalQty != 10 {
+		t.Error("out-of-band ask traded")
+	}
+}
+
+func TestNoBandsByDefault(t *testing.T) {
+	book, pool, rq := bandedBook(101, 500)
+	if o, _ := book.placeOrder(Bid, Market, 0, 50, 20, 50, pool, rq); o.Filled != 20 {
+		t.Errorf("unbanded market filled %d", o.Filled)
+	}
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// ---------------- Published book view ---------------- //
+//
+// Snapshot readers never touch the RBTree or the live order queues. The
+// matcher keeps an immutable copy-on-write view of the visible book and
+// publishes a new version with every L2 delta, so a reader on any goroutine
+// sees the whole book exactly as of one feed sequence.
+//
+// Versions share what did not change: untouched levels are shared by
+// pointer, and so are a level's queue records. New orders are only ever
+// appended past the end of the newest version, the head is held by value
+// so fills do not copy the queue, and it is popped by moving the window.
+// Anything else (a cancel or reduce mid-queue) copies that level's queue.
+// A side's level list is held in chunks, and a delta copies only the chunk
+// it lands in plus the chunk index (see splice).
+//
+// Replaced view memory is retired with the epoch it left the view and
+// recycled once every reader has moved past it, the same way orders go
+// through the retire ring back to the OrderPool.
+
+type bookView struct {
+	seq  uint64   // L2 feed sequence this version reflects
+	last uint64   // book LastSeq when it was published
+	bids sideView // best (highest) first
+	asks sideView // best (lowest) first
+}
+
+// sideChunk bounds the levels in one chunk of a published side.
+const sideChunk = 64
+
+// sideView is one published side, best first, held in chunks of 1 to
+// sideChunk levels. Versions share every chunk a delta left alone.
+type sideView struct {
+	chunks [][]*levelView
+	n      int // levels on the side
+}
+
+func (s sideView) len() int { return s.n }
+
+// each visits the side's levels best first until visit returns false.
+func (s sideView) each(visit func(lv *levelView) bool) {
+	for _, c := range s.chunks {
+		for _, lv := range c {
+			if !visit(lv) {
+				return
+			}
+		}
+	}
+}
+
+// levelView is one published price level.
+type levelView struct {
+	DepthLevel
+	n      int         // orders held: head plus buf[lo:hi]
+	head   viewOrder   // front of the queue
+	buf    []viewOrder // backing array for the rest of the queue
+	lo, hi int
+}
+
+// viewOrder is the published copy of a resting order. It holds no pointers,
+// so view memory costs the garbage collector nothing to scan.
+type viewOrder struct {
+	ID       uint64
+	Account  uint64
+	SeqID    uint64
+	Qty      int64
+	Filled   int64
+	display  int64
+	hidden   int64
+	ExpireAt int64
+	Side     Side
+	Type     OrderType
+	TIF      TimeInForce
+}
+
+func recordOf(o *Order) viewOrder {
+	return viewOrder{
+		ID: o.ID, Account: o.Account, SeqID: o.SeqID, Qty: o.Qty, Filled: o.Filled,
+		display: o.display, hidden: o.hidden, ExpireAt: o.ExpireAt,
+		Side: o.Side, Type: o.Type, TIF: o.TIF,
+	}
+}
+
+func (r *viewOrder) fill(o *Order, price int64) {
+	*o = Order{
+		ID: r.ID, Account: r.Account, Side: r.Side, Type: r.Type, Price: price,
+		Qty: r.Qty, Filled: r.Filled, display: r.display, hidden: r.hidden,
+		TIF: r.TIF, ExpireAt: r.ExpireAt, SeqID: r.SeqID, Status: Active,
+	}
+}
+
+// each visits the level's orders in queue order, rebuilding each into o.
+func (v *levelView) each(o *Order, visit func(price int64, o *Order)) {
+	if v.n == 0 {
+		return
+	}
+	v.head.fill(o, v.Price)
+	visit(v.Price, o)
+	for i := v.lo; i < v.hi; i++ {
+		v.buf[i].fill(o, v.Price)
+		visit(v.Price, o)
+	}
+}
+
+// ---------------- Matcher side ---------------- //
+
+// stage applies an L3-style change of o to lvl's working view, copying the
+// published version first. Called at every visible order change; the next
+// emitL2 for the level publishes it.
+func (b *OrderBook) stage(lvl *PriceLevel, action L3Action, o *Order) {
+	a := &b.arena
+	if !lvl.staged {
+		v := a.level()
+		if lvl.view != nil {
+			*v = *lvl.view
+		}
+		lvl.view, lvl.staged = v, true
+	}
+	v := lvl.view
+	rec := recordOf(o)
+	gone := action == L3Delete || o.Qty == 0
+	switch {
+	case action == L3Add:
+		if v.n == 0 {
+			v.head = rec
+		} else {
+			if v.hi == len(v.buf) {
+				a.copyQueue(v, max(4, 2*(v.hi-v.lo)), -1)
+			}
+			// The newest version always ends at the high-water mark of
+			// its array, so this never writes into a published record.
+			v.buf[v.hi] = rec
+			v.hi++
+		}
+		v.n++
+	case v.head.ID == o.ID:
+		if !gone {
+			v.head = rec
+			return
+		}
+		v.n--
+		if v.lo < v.hi {
+			v.head = v.buf[v.lo]
+			v.lo++
+		}
+	default:
+		i := v.find(o.ID)
+		if i < 0 {
+			return
+		}
+		if gone {
+			a.copyQueue(v, len(v.buf), i)
+			v.n--
+		} else {
+			at := i - v.lo
+			a.copyQueue(v, len(v.buf), -1)
+			v.buf[at] = rec
+		}
+	}
+}
+
+func (v *levelView) find(id uint64) int {
+	for i := v.lo; i < v.hi; i++ {
+		if v.buf[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// publish swaps in a new version with the level at 'price' updated (or
+// removed on L2Delete), stamped with L2 sequence 'seq'.
+func (b *OrderBook) publish(side Side, price int64, lvl *PriceLevel, action L2Action, seq uint64) {
+	a := &b.arena
+	a.collect()
+	cur := b.view.Load()
+	next := a.book()
+	*next = bookView{seq: seq, last: b.LastSeq.Load(), bids: cur.bids, asks: cur.asks}
+
+	var lv *levelView
+	if action == L2Delete {
+		if lvl.staged { // never published
+			a.retire(retiredView{lv: lvl.view})
+		}
+		lvl.view, lvl.staged = nil, false
+	} else {
+		lv = a.freeze(lvl)
+	}
+	levels, old := &next.bids, cur.bids
+	if side == Ask {
+		levels, old = &next.asks, cur.asks
+	}
+	var gone *levelView
+	*levels, gone = a.splice(old, side == Bid, price, lv)
+
+	b.view.Store(next)
+
+	// Unreachable for new readers from here on, with what splice retired.
+	a.retire(retiredView{bv: cur})
+	if gone != nil && gone != lv {
+		r := retiredView{lv: gone}
+		if lv == nil {
+			r.buf = gone.buf
+		}
+		a.retire(r)
+	}
+	a.flush(globalEpoch.Load())
+}
+
+// restamp republishes the current view under the book's present sequences
+// (after they were set directly, as on checkpoint restore).
+func (b *OrderBook) restamp() {
+	a := &b.arena
+	cur := b.view.Load()
+	next := a.book()
+	*next = bookView{seq: b.l2Seq.Load(), last: b.LastSeq.Load(), bids: cur.bids, asks: cur.asks}
+	b.view.Store(next)
+	a.retire(retiredView{bv: cur}) // its sides live on in next
+	a.flush(globalEpoch.Load())
+}
+
+// freeze ends lvl's working view, filling in the level's aggregates.
+func (a *viewArena) freeze(lvl *PriceLevel) *levelView {
+	v := lvl.view
+	if !lvl.staged {
+		if v != nil && v.Qty == lvl.TotalQty && v.Orders == lvl.Count {
+			return v
+		}
+		c := a.level()
+		if v != nil {
+			*c = *v
+		}
+		v = c
+	}
+	v.Price, v.Qty, v.Orders = lvl.Price, lvl.TotalQty, lvl.Count
+	lvl.view, lvl.staged = v, false
+	return v
+}
+
+// splice returns a version of side s (best first) with the level at 'price'
+// replaced or inserted, or removed when lv is nil, plus the level it
+// replaced. Only the chunk holding 'price' and the chunk index are copied,
+// so a delta costs O(sideChunk + levels/sideChunk) on the matcher thread
+// (see BenchmarkDeepBookDelta). A chunk that overflows is split in two and
+// one that runs low is merged with a neighbour. The replaced chunks and
+// index are retired; everything else is shared with s.
+func (a *viewArena) splice(s sideView, desc bool, price int64, lv *levelView) (sideView, *levelView) {
+	behind := func(p int64) bool { return p == price || (p > price) != desc } // at or behind price
+
+	// The first chunk ending at or behind price, else the last one.
+	k, hi := 0, len(s.chunks)
+	for k < hi {
+		m := int(uint(k+hi) >> 1)
+		if c := s.chunks[m]; behind(c[len(c)-1].Price) {
+			hi = m
+		} else {
+			k = m + 1
+		}
+	}
+	if k == len(s.chunks) && k > 0 {
+		k--
+	}
+	var old []*levelView
+	if k < len(s.chunks) {
+		old = s.chunks[k]
+	}
+	i, hi := 0, len(old)
+	for i < hi {
+		m := int(uint(i+hi) >> 1)
+		if behind(old[m].Price) {
+			hi = m
+		} else {
+			i = m + 1
+		}
+	}
+	var gone *levelView
+	end := i
+	if i < len(old) && old[i].Price == price {
+		gone, end = old[i], i+1
+	}
+	if gone == nil && lv == nil {
+		return s, nil
+	}
+
+	n := s.n - (end - i)
+	size := len(old) - (end - i)
+	if lv != nil {
+		n++
+		size++
+	}
+
+	// Rebuild the chunk, taking in a neighbour if it ran low, then cut the
+	// result back into chunks.
+	from, to := k, k+1 // chunks replaced
+	if old == nil {    // empty side
+		to = k
+	}
+	if size > 0 && size < sideChunk/4 {
+		switch {
+		case to < len(s.chunks) && size+len(s.chunks[to]) <= sideChunk:
+			to++
+		case from > 0 && size+len(s.chunks[from-1]) <= sideChunk:
+			from--
+		}
+	}
+	w := a.scratch[:0]
+	for c := from; c < to; c++ {
+		if c != k {
+			w = append(w, s.chunks[c]...)
+			continue
+		}
+		w = append(w, old[:i]...)
+		if lv != nil {
+			w = append(w, lv)
+		}
+		w = append(w, old[end:]...)
+	}
+	if old == nil {
+		w = append(w, lv)
+	}
+	a.scratch = w[:0]
+	parts := (len(w) + sideChunk - 1) / sideChunk
+
+	out := a.index(len(s.chunks) - (to - from) + parts)
+	at := copy(out, s.chunks[:from])
+	for p := 0; p < parts; p++ {
+		out[at] = append(a.chunk(), w[p*len(w)/parts:(p+1)*len(w)/parts]...)
+		at++
+	}
+	copy(out[at:], s.chunks[to:])
+
+	for _, c := range s.chunks[from:to] {
+		a.retire(retiredView{chunk: c})
+	}
+	if s.chunks != nil {
+		a.retire(retiredView{index: s.chunks})
+	}
+	return sideView{chunks: out, n: n}, gone
+}
+
+// ---------------- View memory ---------------- //
+
+// safeEpoch: view memory retired in an earlier epoch is out of every
+// reader's reach. Set by each reclamation pass from the registered readers.
+var safeEpoch atomic.Uint64
+
+const maxViewLimbo = 1 << 16 // beyond this, the oldest retirements go to the GC
+
+type retiredView struct {
+	epoch uint64
+	bv    *bookView
+	lv    *levelView
+	chunk []*levelView
+	index [][]*levelView
+	buf   []viewOrder
+}
+
+// viewArena recycles view memory on the matcher thread. Chunks all have
+// capacity sideChunk; other slices are kept in power-of-two capacity classes.
+type viewArena struct {
+	books   []*bookView
+	levels  []*levelView
+	chunks  [][]*levelView
+	indexes [64][][][]*levelView
+	bufs    [64][][]viewOrder
+	scratch []*levelView  // splice's working chunk
+	limbo   []retiredView // oldest epoch first, live from limbo[lh]
+	lh      int
+	stamp   int // limbo[stamp:] is retired by the change being published
+}
+
+func class(n int) int { return bits.Len(uint(n - 1)) }
+
+func (a *viewArena) book() *bookView {
+	if n := len(a.books); n > 0 {
+		bv := a.books[n-1]
+		a.books = a.books[:n-1]
+		return bv
+	}
+	return &bookView{}
+}
+
+func (a *viewArena) level() *levelView {
+	if n := len(a.levels); n > 0 {
+		lv := a.levels[n-1]
+		a.levels = a.levels[:n-1]
+		*lv = levelView{}
+		return lv
+	}
+	return &levelView{}
+}
+
+func (a *viewArena) chunk() []*levelView {
+	if n := len(a.chunks); n > 0 {
+		c := a.chunks[n-1]
+		a.chunks = a.chunks[:n-1]
+		return c
+	}
+	return make([]*levelView, 0, sideChunk)
+}
+
+func (a *viewArena) index(n int) [][]*levelView {
+	if n == 0 {
+		return nil
+	}
+	c := class(n)
+	if free := a.indexes[c]; len(free) > 0 {
+		s := free[len(free)-1]
+		a.indexes[c] = free[:len(free)-1]
+		return s[:n]
+	}
+	return make([][]*levelView, n, 1<<c)
+}
+
+// copyQueue moves v's queue into a fresh array of at least n slots,
+// dropping index 'skip' (-1 keeps all). The old array stays with the
+// published version and is retired.
+func (a *viewArena) copyQueue(v *levelView, n, skip int) {
+	c := class(n)
+	var nb []viewOrder
+	if free := a.bufs[c]; len(free) > 0 {
+		nb = free[len(free)-1]
+		a.bufs[c] = free[:len(free)-1]
+	} else {
+		nb = make([]viewOrder, 1<<c)
+	}
+	k := 0
+	for i := v.lo; i < v.hi; i++ {
+		if i != skip {
+			nb[k] = v.buf[i]
+			k++
+		}
+	}
+	if v.buf != nil {
+		a.retire(retiredView{buf: v.buf})
+	}
+	v.buf, v.lo, v.hi = nb, 0, k
+}
+
+func (a *viewArena) retire(r retiredView) { a.limbo = append(a.limbo, r) }
+
+// flush stamps what the last publish made unreachable.
+func (a *viewArena) flush(epoch uint64) {
+	for i := a.stamp; i < len(a.limbo); i++ {
+		a.limbo[i].epoch = epoch
+	}
+	a.stamp = len(a.limbo)
+	if over := a.stamp - a.lh - maxViewLimbo; over > 0 {
+		a.drop(over, false)
+	}
+}
+
+// collect recycles retirements no reader can still see.
+func (a *viewArena) collect() {
+	safe := safeEpoch.Load()
+	if safe > globalEpoch.Load() { // epoch was reset; wait for a fresh pass
+		return
+	}
+	n := 0
+	for a.lh+n < a.stamp && a.limbo[a.lh+n].epoch < safe {
+		n++
+	}
+	a.drop(n, true)
+}
+
+// drop removes the n oldest retirements, recycling them or leaving them to
+// the garbage collector.
+func (a *viewArena) drop(n int, recycle bool) {
+	if n == 0 {
+		return
+	}
+	gone := a.limbo[a.lh : a.lh+n]
+	if recycle {
+		for _, r := range gone {
+			if r.bv != nil {
+				a.books = append(a.books, r.bv)
+			}
+			if r.lv != nil {
+				a.levels = append(a.levels, r.lv)
+			}
+			if r.chunk != nil {
+				a.chunks = append(a.chunks, r.chunk[:0])
+			}
+			if cap(r.index) > 0 {
+				c := class(cap(r.index))
+				a.indexes[c] = append(a.indexes[c], r.index[:cap(r.index)])
+			}
+			if len(r.buf) > 0 {
+				c := class(len(r.buf))
+				a.bufs[c] = append(a.bufs[c], r.buf)
+			}
+		}
+	} else {
+		clear(gone)
+	}
+	a.lh += n
+	if a.lh > len(a.limbo)/2 { // compact, amortised over the drops since
+		k := copy(a.limbo, a.limbo[a.lh:])
+		a.limbo, a.stamp, a.lh = a.limbo[:k], a.stamp-a.lh, 0
+	}
+}
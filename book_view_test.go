@@ -0,0 +1,304 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// liveRows walks the matcher's own structures (single goroutine only).
+func liveRows(b *OrderBook) []l3Row {
+	var rows []l3Row
+	walk := func(lvl *PriceLevel) bool {
+		for o := lvl.head; o != nil; o = o.next {
+			rows = append(rows, l3Row{o.ID, lvl.Price, o.Qty})
+		}
+		return true
+	}
+	b.Bids.ForEachDescending(walk)
+	b.Asks.ForEachAscending(walk)
+	return rows
+}
+
+// checkView verifies one published version is internally consistent.
+func checkView(v *bookView) string {
+	for side, levels := range []sideView{v.bids, v.asks} {
+		n := 0
+		for _, c := range levels.chunks {
+			if len(c) == 0 || len(c) > sideChunk {
+				return "chunk size out of range"
+			}
+			n += len(c)
+		}
+		if n != levels.len() {
+			return "side length disagrees with its chunks"
+		}
+		var prev *levelView
+		msg := ""
+		levels.each(func(lv *levelView) bool {
+			if prev != nil && (lv.Price < prev.Price) != (side == 0) {
+				msg = "levels out of order"
+				return false
+			}
+			prev = lv
+			var qty int64
+			lv.each(&Order{}, func(_ int64, o *Order) { qty += o.Qty })
+			if lv.n != lv.Orders || qty != lv.Qty || lv.n == 0 {
+				msg = "level aggregates disagree with its orders"
+				return false
+			}
+			return true
+		})
+		if msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func TestViewTracksLiveBook(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	book.SetSelfTradePrevention(STPDecrement, nil)
+	rng := rand.New(rand.NewSource(3))
+
+	for step := 0; step < 300; step++ {
+		churn(book, pool, rq, rng, uint64(step*10+1), 10)
+		id := uint64(100_000 + step)
+		_, _ = book.submit(OrderSpec{ID: id, Seq: id, Account: 7, Side: Side(step % 2), Type: Limit, Price: int64(98 + rng.Intn(5)), Qty: 5}, pool, rq)
+
+		want, got := liveRows(book), l3Rows(book)
+		if len(got) != len(want) {
+			t.Fatalf("step %d: view has %d orders, book %d", step, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("step %d row %d = %+v, want %+v", step, i, got[i], want[i])
+			}
+		}
+		if msg := checkView(book.view.Load()); msg != "" {
+			t.Fatalf("step %d: %s", step, msg)
+		}
+	}
+	if book.view.Load().seq != book.l2Seq.Load() {
+		t.Error("view not stamped with the last feed sequence")
+	}
+}
+
+func TestOldViewUnaffectedByLaterChanges(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	for i := uint64(1); i <= 6; i++ {
+		_, _ = book.placeOrder(Bid, Limit, 100, i, 10, i, pool, rq)
+	}
+	old := book.view.Load()
+	var before []l3Row
+	old.bids.each(func(lv *levelView) bool {
+		lv.each(&Order{}, func(p int64, o *Order) { before = append(before, l3Row{o.ID, p, o.Qty}) })
+		return true
+	})
+
+	_, _ = book.placeOrder(Ask, IOC, 100, 7, 15, 7, pool, rq)     // fill head, partial second
+	_ = book.CancelByID(4, rq)                                    // mid-queue cancel
+	_, _ = book.Amend(5, 100, 3, 8, rq)                           // mid-queue reduce
+	_, _ = book.placeOrder(Bid, Limit, 100, 9, 10, 9, pool, rq)   // append
+	_, _ = book.placeOrder(Bid, Limit, 100, 10, 10, 10, pool, rq) // append again
+
+	var after []l3Row
+	old.bids.each(func(lv *levelView) bool {
+		lv.each(&Order{}, func(p int64, o *Order) { after = append(after, l3Row{o.ID, p, o.Qty}) })
+		return true
+	})
+	if len(after) != len(before) {
+		t.Fatalf("old version changed length: %+v -> %+v", before, after)
+	}
+	for i := range before {
+		if after[i] != before[i] {
+			t.Errorf("old version row %d changed: %+v -> %+v", i, before[i], after[i])
+		}
+	}
+	want := []l3Row{{2, 100, 5}, {3, 100, 10}, {5, 100, 3}, {6, 100, 10}, {9, 100, 10}, {10, 100, 10}}
+	got := l3Rows(book)
+	if len(got) != len(want) {
+		t.Fatalf("new version = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("new version row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// A deep side is spliced chunk by chunk: each version must match the live
+// book, share the chunks a delta left alone, and leave older versions as
+// they were.
+func TestDeepSideSharesChunks(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	rng := rand.New(rand.NewSource(5))
+	live := map[uint64]bool{}
+	place := func(id uint64) {
+		side, price := Bid, int64(1+rng.Intn(1500))
+		if id%2 == 1 {
+			side, price = Ask, price+2000
+		}
+		_, _ = book.placeOrder(side, Limit, price, id, 1, id, pool, rq)
+		live[id] = true
+	}
+	for id := uint64(1); id <= 1000; id++ {
+		place(id)
+	}
+	old := book.view.Load()
+	before := l3Rows(book)
+
+	for step := 0; step < 2000; step++ {
+		cur := book.view.Load()
+		id := uint64(1 + rng.Intn(1200))
+		if live[id] {
+			_ = book.CancelByID(id, rq)
+			delete(live, id)
+		} else {
+			place(id)
+		}
+
+		want, got := liveRows(book), l3Rows(book)
+		if len(got) != len(want) {
+			t.Fatalf("step %d: view has %d orders, book %d", step, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("step %d row %d = %+v, want %+v", step, i, got[i], want[i])
+			}
+		}
+		next := book.view.Load()
+		if msg := checkView(next); msg != "" {
+			t.Fatalf("step %d: %s", step, msg)
+		}
+		for _, s := range [][2]sideView{{cur.bids, next.bids}, {cur.asks, next.asks}} {
+			shared := map[**levelView]bool{}
+			for _, c := range s[0].chunks {
+				shared[&c[0]] = true
+			}
+			n := 0
+			for _, c := range s[1].chunks {
+				if shared[&c[0]] {
+					n++
+				}
+			}
+			if n < len(s[1].chunks)-2 {
+				t.Fatalf("step %d: %d of %d chunks shared with the last version", step, n, len(s[1].chunks))
+			}
+		}
+	}
+
+	var after []l3Row
+	for _, side := range []sideView{old.bids, old.asks} {
+		side.each(func(lv *levelView) bool {
+			lv.each(&Order{}, func(p int64, o *Order) { after = append(after, l3Row{o.ID, p, o.Qty}) })
+			return true
+		})
+	}
+	if len(after) != len(before) {
+		t.Fatalf("old version changed length: %d -> %d", len(before), len(after))
+	}
+	for i := range before {
+		if after[i] != before[i] {
+			t.Fatalf("old version row %d changed: %+v -> %+v", i, before[i], after[i])
+		}
+	}
+}
+
+// Snapshot readers run on their own goroutines while the matcher churns.
+// Run with -race: readers must never touch state the matcher writes.
+func TestConcurrentSnapshotsDuringChurn(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan string, 4)
+
+	for i := 0; i < 4; i++ {
+		r := RegisterReader()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer UnregisterReader(r)
+			var d Depth
+			var lastFeed uint64
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if i%2 == 0 {
+					book.SnapshotDepth(r, 10, &d)
+					if d.FeedSeq < lastFeed {
+						errs <- "feed sequence went backwards"
+						return
+					}
+					lastFeed = d.FeedSeq
+					continue
+				}
+				r.EnterRead()
+				msg := checkView(book.view.Load())
+				r.ExitRead()
+				if msg != "" {
+					errs <- msg
+					return
+				}
+				book.SnapshotActiveIter(r, func(int64, *Order) {})
+			}
+		}(i)
+	}
+
+	rng := rand.New(rand.NewSource(5))
+	churn(book, pool, rq, rng, 1, 20_000)
+	close(stop)
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Error(msg)
+	}
+}
+
+// A depth snapshot taken on another goroutine mid-churn plus the deltas
+// after its FeedSeq rebuilds the final book.
+func TestConcurrentDepthSnapshotSyncsL2(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	var deltas []L2Delta
+	book.SetL2Sink(L2SinkFunc(func(d L2Delta) { deltas = append(deltas, d) }))
+	rng := rand.New(rand.NewSource(9))
+	churn(book, pool, rq, rng, 1, 500)
+
+	var snap Depth
+	r := RegisterReader()
+	defer UnregisterReader(r)
+	taken := make(chan struct{})
+	go func() {
+		book.SnapshotDepth(r, 1<<20, &snap)
+		close(taken)
+	}()
+	churn(book, pool, rq, rng, 1_000, 5_000)
+	<-taken
+
+	lb := NewL2Book(&snap)
+	for _, d := range deltas {
+		if err := lb.Apply(d); err != nil {
+			t.Fatalf("delta %d: %v", d.Seq, err)
+		}
+	}
+	var live Depth
+	book.SnapshotDepth(&Reader{}, 1<<20, &live)
+	for _, side := range []Side{Bid, Ask} {
+		want := live.Bids
+		if side == Ask {
+			want = live.Asks
+		}
+		got := lb.Levels(side)
+		if len(got) != len(want) {
+			t.Fatalf("side %d: %d levels, want %d", side, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("side %d level %d = %+v, want %+v", side, i, got[i], want[i])
+			}
+		}
+	}
+}
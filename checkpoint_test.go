@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	var wal bytes.Buffer
+	orig, _ := runJournaled(t, &wal, FsyncNone)
+
+	var ckpt bytes.Buffer
+	if err := WriteCheckpoint(&ckpt, orig, 15); err != nil {
+		t.Fatal(err)
+	}
+	restored, pool, rq := newTestEnv()
+	info, err := RestoreCheckpoint(bytes.NewReader(ckpt.Bytes()), restored, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.JournalSeq != 15 || info.LastSeq != orig.LastSeq.Load() {
+		t.Errorf("unexpected header %+v", info)
+	}
+	if a, b := dumpBook(orig), dumpBook(restored); !bytes.Equal(a, b) {
+		t.Fatalf("restored book differs:\n--- original\n%s--- restored\n%s", a, b)
+	}
+
+	// Same priority: the next aggressor trades identically on both books
+	origRec, restRec := &execRecorder{}, &execRecorder{}
+	orig.SetExecutionSink(origRec)
+	restored.SetExecutionSink(restRec)
+	_, _ = orig.placeOrder(Bid, Limit, 110, 100, 50, 100, NewOrderPool(8), newRetireRing(64))
+	_, _ = restored.placeOrder(Bid, Limit, 110, 100, 50, 100, pool, rq)
+	if len(origRec.execs) == 0 || len(origRec.execs) != len(restRec.execs) {
+		t.Fatalf("expected identical fills, got %d vs %d", len(origRec.execs), len(restRec.execs))
+	}
+	for i := range origRec.execs {
+		if origRec.execs[i] != restRec.execs[i] {
+			t.Errorf("fill %d differs: %+v vs %+v", i, origRec.execs[i], restRec.execs[i])
+		}
+	}
+}
+
+func TestCheckpointPlusJournalTail(t *testing.T) {
+	cmds := scriptedCommands()
+	book, pool, rq := newTestEnv()
+	wall := NewManualClock(1_000)
+	book.SetClock(wall)
+	var wal, ckpt bytes.Buffer
+	jb := NewJournaledBook(book, NewJournal(&wal, FsyncNone, 0, 0), pool, rq)
+
+	for i, c := range cmds {
+		if i == 7 {
+			if err := jb.Checkpoint(&ckpt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := jb.Do(c); err != nil {
+			t.Fatal(err)
+		}
+		wall.Advance(100)
+	}
+
+	// Restart: load checkpoint, roll forward the journal after its offset
+	restored, pool2, rq2 := newTestEnv()
+	info, err := RestoreCheckpoint(bytes.NewReader(ckpt.Bytes()), restored, pool2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.JournalSeq != 7 {
+		t.Fatalf("expected checkpoint at journal seq 7, got %d", info.JournalSeq)
+	}
+	last, err := ReplayAfter(bytes.NewReader(wal.Bytes()), info.JournalSeq, restored, pool2, rq2)
+	if err != nil || last != uint64(len(cmds)) {
+		t.Fatalf("roll forward: last=%d err=%v", last, err)
+	}
+	if a, b := dumpBook(book), dumpBook(restored); !bytes.Equal(a, b) {
+		t.Fatalf("checkpoint+journal differs:\n--- original\n%s--- restored\n%s", a, b)
+	}
+}
+
+func TestCheckpointRejectsDamage(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq)
+	var ckpt bytes.Buffer
+	_ = WriteCheckpoint(&ckpt, book, 0)
+	data := ckpt.Bytes()
+
+	bad := append([]byte(nil), data...)
+	bad[len(bad)-10] ^= 0x01
+	if _, err := RestoreCheckpoint(bytes.NewReader(bad), NewOrderBook(), NewOrderPool(8)); err != ErrCheckpointBad {
+		t.Errorf("expected crc failure, got %v", err)
+	}
+
+	ver := append([]byte(nil), data...)
+	ver[4] = 9
+	if _, err := RestoreCheckpoint(bytes.NewReader(ver), NewOrderBook(), NewOrderPool(8)); err != ErrCheckpointVer {
+		t.Errorf("expected version error, got %v", err)
+	}
+
+	// A version 2 file of an empty book: shorter than the current header.
+	v2 := append(ckptMagic[:], 2, 0)
+	v2 = append(v2, make([]byte, 8*7)...)
+	v2 = binary.LittleEndian.AppendUint32(v2, crc32.Checksum(v2, crcTable))
+	if _, err := RestoreCheckpoint(bytes.NewReader(v2), NewOrderBook(), NewOrderPool(8)); err != ErrCheckpointVer {
+		t.Errorf("expected version error for a version 2 file, got %v", err)
+	}
+
+	if _, err := RestoreCheckpoint(bytes.NewReader(data), book, pool); err != ErrCheckpointDirty {
+		t.Errorf("expected non-empty book refused, got %v", err)
+	}
+}
+
+func TestCheckpointDamageLeavesBookAndPoolUntouched(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Bid, Limit, 99, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 101, 2, 5, 2, pool, rq)
+	var ckpt bytes.Buffer
+	_ = WriteCheckpoint(&ckpt, book, 0)
+
+	for _, at := range []int{ckpt.Len() - 1, ckpt.Len() - 10, ckpt.Len() - 4 - ckptOrderLen/2} {
+		bad := append([]byte(nil), ckpt.Bytes()...)
+		bad[at] ^= 0x01
+		restored, rpool := NewOrderBook(), NewOrderPool(8)
+		if _, err := RestoreCheckpoint(bytes.NewReader(bad), restored, rpool); err != ErrCheckpointBad {
+			t.Fatalf("byte %d: expected crc failure, got %v", at, err)
+		}
+		if restored.index.live != 0 || restored.Bids.Size() != 0 || restored.Asks.Size() != 0 {
+			t.Errorf("byte %d: damaged checkpoint left orders in the book", at)
+		}
+		if rpool.Available() != 8 {
+			t.Errorf("byte %d: pool leaked %d orders", at, 8-rpool.Available())
+		}
+	}
+}
+
+func TestCheckpointContinuesFeedSequences(t *testing.T) {
+	var wal bytes.Buffer
+	orig, _ := runJournaled(t, &wal, FsyncNone)
+	var ckpt bytes.Buffer
+	if err := WriteCheckpoint(&ckpt, orig, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, pool, rq := newTestEnv()
+	var l2 []L2Delta
+	var l3 []L3Event
+	restored.SetL2Sink(L2SinkFunc(func(d L2Delta) { l2 = append(l2, d) }))
+	restored.SetL3Sink(L3SinkFunc(func(e L3Event) { l3 = append(l3, e) }))
+	if _, err := RestoreCheckpoint(bytes.NewReader(ckpt.Bytes()), restored, pool); err != nil {
+		t.Fatal(err)
+	}
+	if len(l2) != 0 || len(l3) != 0 {
+		t.Fatalf("restore emitted %d L2 and %d L3 events", len(l2), len(l3))
+	}
+	if restored.l2Seq.Load() != orig.l2Seq.Load() || restored.l3Seq != orig.l3Seq {
+		t.Fatalf("feeds at %d/%d, want %d/%d", restored.l2Seq.Load(), restored.l3Seq, orig.l2Seq.Load(), orig.l3Seq)
+	}
+	var d Depth
+	restored.SnapshotDepth(&Reader{}, 10, &d)
+	if d.FeedSeq != orig.l2Seq.Load() || d.Seq != orig.LastSeq.Load() {
+		t.Errorf("restored view stamped %d/%d", d.FeedSeq, d.Seq)
+	}
+
+	_, _ = restored.placeOrder(Bid, Limit, 50, 999, 1, 999, pool, rq)
+	if len(l2) != 1 || l2[0].Seq != orig.l2Seq.Load()+1 || len(l3) != 1 || l3[0].Seq != orig.l3Seq+1 {
+		t.Errorf("feeds did not carry on: %+v %+v", l2, l3)
+	}
+}
+
+func TestCheckpointKeepsSession(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_ = book.StartAuction()
+	_, _ = book.placeOrder(Bid, Limit, 101, 1, 5, 1, pool, rq)
+	_, _ = book.SetSession(Halted, rq)
+	var ckpt bytes.Buffer
+	if err := WriteCheckpoint(&ckpt, book, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, pool2, rq2 := newTestEnv()
+	var events []SessionEvent
+	restored.SetSessionSink(SessionSinkFunc(func(e SessionEvent) { events = append(events, e) }))
+	if _, err := RestoreCheckpoint(bytes.NewReader(ckpt.Bytes()), restored, pool2); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Session() != Halted || !restored.InAuction() {
+		t.Fatalf("restored book in %v", restored.Session())
+	}
+	if _, r := restored.placeOrder(Ask, Limit, 100, 2, 5, 2, pool2, rq2); r != RejectSessionState {
+		t.Errorf("restored halted book accepted a crossing ask: %v", r)
+	}
+	if _, err := restored.SetSession(Continuous, rq2); err != nil || len(events) != 1 || events[0].Seq != 3 {
+		t.Errorf("session sequence did not carry on: %v %+v", err, events)
+	}
+}
+
+func TestCheckpointKeepsBandReference(t *testing.T) {
+	book, pool, rq := bandedBook(103, 104, 108)
+	book.SetReferencePrice(100)
+	book.SetPriceBands(PriceBands{Static: 5, Action: BandAuction}, nil)
+	_, _ = book.placeOrder(Bid, Limit, 120, 50, 30, 50, pool, rq) // breaches at 108
+	var ckpt bytes.Buffer
+	if err := WriteCheckpoint(&ckpt, book, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, pool2, rq2 := newTestEnv()
+	var breaches []BandBreach
+	restored.SetPriceBands(PriceBands{Static: 5}, BandSinkFunc(func(e BandBreach) { breaches = append(breaches, e) }))
+	if _, err := RestoreCheckpoint(bytes.NewReader(ckpt.Bytes()), restored, pool2); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Session() != VolatilityAuction || restored.auction.ref != 100 {
+		t.Fatalf("restored %v with auction ref %d", restored.Session(), restored.auction.ref)
+	}
+	if lo, hi := restored.bandRange(); lo != 95 || hi != 105 {
+		t.Errorf("restored band [%d, %d], want [95, 105]", lo, hi)
+	}
+
+	// The uncross re-anchors the band; the next breach continues the sequence.
+	if res, err := restored.Uncross(rq2); err != nil || res.Price != 108 {
+		t.Fatalf("uncross %+v, %v", res, err)
+	}
+	_, _ = restored.placeOrder(Ask, Limit, 130, 60, 5, 60, pool2, rq2)
+	_, _ = restored.placeOrder(Bid, Market, 0, 61, 5, 61, pool2, rq2)
+	if len(breaches) != 1 || breaches[0].Seq != 2 || breaches[0].High != 113 {
+		t.Errorf("breaches after restore %+v", breaches)
+	}
+}
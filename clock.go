@@ -0,0 +1,22 @@
+package main
+
+import "time"
+
+// Clock supplies the engine's notion of time in nanoseconds.
+// Inject a ManualClock for tests and journal replay.
+type Clock interface {
+	Now() int64
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() int64 { return time.Now().UnixNano() }
+
+// ManualClock only moves when told to (single-threaded use).
+type ManualClock struct{ now int64 }
+
+func NewManualClock(start int64) *ManualClock { return &ManualClock{now: start} }
+
+func (c *ManualClock) Now() int64              { return c.now }
+func (c *ManualClock) Set(t int64)             { c.now = t }
+func (c *ManualClock) Advance(d time.Duration) { c.now += int64(d) }
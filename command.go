@@ -0,0 +1,62 @@
+package main
+
+// CommandKind identifies an inbound request to a book.
+type CommandKind uint8
+
+const (
+	CmdPlace    CommandKind = iota + 1
+	CmdCancel               // cancel by ID
+	CmdAmend                // amend by ID
+	CmdExpire               // run the GTD scheduler
+	CmdEndOfDay             // expire all DAY orders
+	CmdAuction              // start an auction call
+	CmdUncross              // end the call at the equilibrium price
+	CmdSession              // move to another session state
+)
+
+// Command is one inbound request, in the form it is journaled and replayed.
+type Command struct {
+	Kind  CommandKind
+	Time  int64        // clock time the command is applied at
+	Order OrderSpec    // CmdPlace
+	ID    uint64       // CmdCancel, CmdAmend
+	Price int64        // CmdAmend
+	Qty   int64        // CmdAmend
+	Seq   uint64       // CmdAmend
+	State SessionState // CmdSession
+}
+
+// CommandResult is the outcome of applying a Command.
+type CommandResult struct {
+	Order   *Order // CmdPlace: the accepted order (nil if rejected)
+	Amend   AmendResult
+	Reject  RejectReason
+	Count   int           // CmdExpire, CmdEndOfDay: orders expired
+	Auction AuctionResult // CmdUncross, CmdSession: what was executed
+	Err     error         // CmdSession, CmdAuction, CmdUncross: transition not allowed
+}
+
+// Apply runs a command against the book. Time is not applied here; the
+// caller owns the book's clock.
+func (b *OrderBook) Apply(c Command, pool *OrderPool, rq *retireRing) CommandResult {
+	var res CommandResult
+	switch c.Kind {
+	case CmdPlace:
+		res.Order, res.Reject = b.submit(c.Order, pool, rq)
+	case CmdCancel:
+		res.Reject = b.CancelByID(c.ID, rq)
+	case CmdAmend:
+		res.Amend, res.Reject = b.Amend(c.ID, c.Price, c.Qty, c.Seq, rq)
+	case CmdExpire:
+		res.Count = b.ExpireOrders(rq)
+	case CmdEndOfDay:
+		res.Count = b.EndOfDay(rq)
+	case CmdAuction:
+		res.Err = b.StartAuction()
+	case CmdUncross:
+		res.Auction, res.Err = b.Uncross(rq)
+	case CmdSession:
+		res.Auction, res.Err = b.SetSession(c.State, rq)
+	}
+	return res
+}
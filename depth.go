@@ -0,0 +1,44 @@
+package main
+
+// DepthLevel is one aggregated (L2) price level. Qty is the displayed
+// quantity only; iceberg reserves are never exposed.
+type DepthLevel struct {
+	Price  int64
+	Qty    int64
+	Orders int
+}
+
+// Depth is a top-of-book view. Bids are best (highest) first, asks best
+// (lowest) first. Seq is the book's LastSeq and FeedSeq the last L2 delta
+// as of the published version the levels come from.
+type Depth struct {
+	Seq     uint64
+	FeedSeq uint64
+	Bids    []DepthLevel
+	Asks    []DepthLevel
+}
+
+// SnapshotDepth fills d with up to n levels per side, reusing the capacity
+// of d.Bids and d.Asks (no allocation once they hold n levels). Reads the
+// published view, so it is safe on any goroutine.
+func (b *OrderBook) SnapshotDepth(r *Reader, n int, d *Depth) {
+	r.EnterRead()
+	v := b.view.Load()
+	d.Seq, d.FeedSeq = v.last, v.seq
+	d.Bids = appendDepth(d.Bids[:0], n, v.bids)
+	d.Asks = appendDepth(d.Asks[:0], n, v.asks)
+	r.ExitRead()
+}
+
+// appendDepth copies up to n levels, best first.
+func appendDepth(dst []DepthLevel, n int, side sideView) []DepthLevel {
+	for _, c := range side.chunks {
+		for _, lv := range c {
+			if len(dst) >= n {
+				return dst
+			}
+			dst = append(dst, lv.DepthLevel)
+		}
+	}
+	return dst
+}
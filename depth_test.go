@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestSnapshotDepth(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Bid, Limit, 99, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 99, 2, 7, 2, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 98, 3, 1, 3, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 97, 4, 1, 4, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 101, 5, 4, 5, pool, rq)
+	_, _ = placeIceberg(book, Ask, 102, 6, 50, 10, pool, rq)
+
+	var d Depth
+	book.SnapshotDepth(&Reader{}, 2, &d)
+
+	if d.Seq != 6 {
+		t.Errorf("expected depth stamped with seq 6, got %d", d.Seq)
+	}
+	wantBids := []DepthLevel{{99, 12, 2}, {98, 1, 1}}
+	wantAsks := []DepthLevel{{101, 4, 1}, {102, 10, 1}}
+	if len(d.Bids) != 2 || d.Bids[0] != wantBids[0] || d.Bids[1] != wantBids[1] {
+		t.Errorf("bids = %+v, want %+v", d.Bids, wantBids)
+	}
+	if len(d.Asks) != 2 || d.Asks[0] != wantAsks[0] || d.Asks[1] != wantAsks[1] {
+		t.Errorf("asks = %+v, want %+v (iceberg shows display only)", d.Asks, wantAsks)
+	}
+
+	// Partial fill and cancel are reflected in qty and order count
+	_, _ = book.placeOrder(Ask, Limit, 99, 7, 6, 7, pool, rq)
+	_ = book.CancelByID(3, rq)
+	book.SnapshotDepth(&Reader{}, 5, &d)
+	if len(d.Bids) != 2 || d.Bids[0] != (DepthLevel{99, 6, 1}) || d.Bids[1] != (DepthLevel{97, 1, 1}) {
+		t.Errorf("unexpected bids after trade/cancel %+v", d.Bids)
+	}
+}
+
+// Seq comes from the same version as the levels, not from the live book.
+func TestSnapshotDepthSeqMatchesLevels(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Bid, Limit, 99, 1, 5, 1, pool, rq)
+	_, _ = book.submit(OrderSpec{ID: 2, Seq: 2, Side: Bid, Type: Stop, StopPrice: 105, Qty: 5}, pool, rq)
+
+	var d Depth
+	book.SnapshotDepth(&Reader{}, 10, &d)
+	if book.LastSeq.Load() != 2 || d.Seq != 1 {
+		t.Errorf("depth stamped %d, want 1 (the stop changed nothing visible)", d.Seq)
+	}
+}
+
+func TestSnapshotDepthNoAlloc(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	for i := 0; i < 20; i++ {
+		_, _ = book.placeOrder(Bid, Limit, int64(100-i), uint64(i+1), 5, uint64(i+1), pool, rq)
+	}
+	d := Depth{Bids: make([]DepthLevel, 0, 10), Asks: make([]DepthLevel, 0, 10)}
+	r := &Reader{}
+	allocs := testing.AllocsPerRun(100, func() { book.SnapshotDepth(r, 10, &d) })
+	if allocs != 0 {
+		t.Errorf("expected no allocations with a sized buffer, got %.1f", allocs)
+	}
+	if len(d.Bids) != 10 || d.Bids[9].Price != 91 {
+		t.Errorf("expected top 10 bids down to 91, got %d levels", len(d.Bids))
+	}
+}
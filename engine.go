@@ -0,0 +1,188 @@
+package main
+
+// Engine hosts one OrderBook per instrument symbol and routes commands to
+// it. Books either share the engine's OrderPool/retireRing or bring their
+// own; each pool is always paired with the ring its orders retire into.
+// Like OrderBook, an Engine is driven by a single matcher thread; a Matcher
+// serves it to many goroutines.
+
+type bookSlot struct {
+	symbol string
+	book   *OrderBook
+	pool   *OrderPool
+	rq     *retireRing
+}
+
+type recycler struct {
+	pool *OrderPool
+	rq   *retireRing
+}
+
+type Engine struct {
+	books     map[string]*bookSlot
+	slots     []*bookSlot // insertion order, for deterministic sweeps
+	recyclers []recycler  // distinct pool/ring pairs
+	pool      *OrderPool  // shared by books added with AddBook
+	rq        *retireRing
+	reclaimer *Reclaimer // nil: Reclaim recycles on the matcher thread
+}
+
+func NewEngine(pool *OrderPool, rq *retireRing) *Engine {
+	return &Engine{
+		books:     make(map[string]*bookSlot),
+		pool:      pool,
+		rq:        rq,
+		recyclers: []recycler{{pool, rq}},
+	}
+}
+
+// AddBook creates the book for 'symbol' with default reference data on the
+// shared pool and ring. Returns the existing book if already listed.
+func (e *Engine) AddBook(symbol string) *OrderBook {
+	return e.AddInstrument(Instrument{Symbol: symbol}, e.pool, e.rq)
+}
+
+// AddBookWithPools creates the book for 'symbol' with a dedicated pool and
+// retire ring (e.g. to isolate a busy instrument).
+func (e *Engine) AddBookWithPools(symbol string, pool *OrderPool, rq *retireRing) *OrderBook {
+	return e.AddInstrument(Instrument{Symbol: symbol}, pool, rq)
+}
+
+// AddInstrument lists 'inst' on the given pool and ring (nil = shared).
+func (e *Engine) AddInstrument(inst Instrument, pool *OrderPool, rq *retireRing) *OrderBook {
+	if s, ok := e.books[inst.Symbol]; ok {
+		return s.book
+	}
+	if pool == nil || rq == nil {
+		pool, rq = e.pool, e.rq
+	}
+	s := &bookSlot{symbol: inst.Symbol, book: NewOrderBookFor(inst), pool: pool, rq: rq}
+	e.books[inst.Symbol] = s
+	e.slots = append(e.slots, s)
+	e.addRecycler(pool, rq)
+	return s.book
+}
+
+func (e *Engine) addRecycler(pool *OrderPool, rq *retireRing) {
+	for _, r := range e.recyclers {
+		if r.rq == rq {
+			return
+		}
+	}
+	e.recyclers = append(e.recyclers, recycler{pool, rq})
+	if e.reclaimer != nil {
+		e.reclaimer.Add(pool, rq)
+	}
+}
+
+// Book returns the book for 'symbol' or nil.
+func (e *Engine) Book(symbol string) *OrderBook {
+	if s, ok := e.books[symbol]; ok {
+		return s.book
+	}
+	return nil
+}
+
+// Symbols returns listed instruments in the order they were added.
+func (e *Engine) Symbols() []string {
+	out := make([]string, len(e.slots))
+	for i, s := range e.slots {
+		out[i] = s.symbol
+	}
+	return out
+}
+
+// ---------------- Command routing ---------------- //
+
+func (e *Engine) Place(symbol string, spec OrderSpec) (*Order, RejectReason) {
+	s, ok := e.books[symbol]
+	if !ok {
+		return nil, RejectUnknownSymbol
+	}
+	return s.book.submit(spec, s.pool, s.rq)
+}
+
+func (e *Engine) Cancel(symbol string, id uint64) RejectReason {
+	s, ok := e.books[symbol]
+	if !ok {
+		return RejectUnknownSymbol
+	}
+	return s.book.CancelByID(id, s.rq)
+}
+
+func (e *Engine) Amend(symbol string, id uint64, newPrice, newQty int64, seq uint64) (AmendResult, RejectReason) {
+	s, ok := e.books[symbol]
+	if !ok {
+		return AmendRejected, RejectUnknownSymbol
+	}
+	return s.book.Amend(id, newPrice, newQty, seq, s.rq)
+}
+
+// Apply routes a command to the book for 'symbol'. Time is not applied
+// here; see OrderBook.Apply.
+func (e *Engine) Apply(symbol string, c Command) CommandResult {
+	s, ok := e.books[symbol]
+	if !ok {
+		return CommandResult{Reject: RejectUnknownSymbol}
+	}
+	return s.book.Apply(c, s.pool, s.rq)
+}
+
+// Snapshot walks the active orders of one instrument.
+func (e *Engine) Snapshot(symbol string, r *Reader, visit func(price int64, o *Order)) RejectReason {
+	s, ok := e.books[symbol]
+	if !ok {
+		return RejectUnknownSymbol
+	}
+	s.book.SnapshotActiveIter(r, visit)
+	return RejectNone
+}
+
+// ---------------- Housekeeping across books ---------------- //
+
+// ExpireOrders runs the GTD scheduler of every book.
+func (e *Engine) ExpireOrders() int {
+	n := 0
+	for _, s := range e.slots {
+		n += s.book.ExpireOrders(s.rq)
+	}
+	return n
+}
+
+// EndOfDay expires DAY orders in every book.
+func (e *Engine) EndOfDay() int {
+	n := 0
+	for _, s := range e.slots {
+		n += s.book.EndOfDay(s.rq)
+	}
+	return n
+}
+
+// Reclaim advances the epoch and recycles retired orders of every
+// pool/ring pair. All books share the process-wide epoch and readers.
+// With a background reclaimer running it only asks for an early pass.
+func (e *Engine) Reclaim() {
+	if e.reclaimer != nil {
+		e.reclaimer.Kick()
+		return
+	}
+	for _, r := range e.recyclers {
+		advanceEpochAndReclaim(r.rq, r.pool)
+	}
+}
+
+// StartReclaimer hands reclamation of every pool/ring pair, including those
+// of books added later, to a background Reclaimer. Stop it once the engine
+// is done; Reclaim must not be relied on to recycle after that.
+func (e *Engine) StartReclaimer(cfg ReclaimerConfig) *Reclaimer {
+	if e.reclaimer != nil {
+		return e.reclaimer
+	}
+	rc := NewReclaimer(cfg)
+	for _, r := range e.recyclers {
+		rc.Add(r.pool, r.rq)
+	}
+	e.reclaimer = rc
+	rc.Start()
+	return rc
+}
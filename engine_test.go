@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEngineRoutesBySymbol(t *testing.T) {
+	e := NewEngine(NewOrderPool(1<<10), newRetireRing(1<<10))
+	aapl := e.AddBook("AAPL")
+	msft := e.AddBook("MSFT")
+	if e.AddBook("AAPL") != aapl {
+		t.Fatal("re-adding a symbol must return the existing book")
+	}
+
+	// Same ID and price in two books must not interact
+	_, _ = e.Place("AAPL", OrderSpec{ID: 1, Seq: 1, Side: Bid, Type: Limit, Price: 100, Qty: 5})
+	_, _ = e.Place("MSFT", OrderSpec{ID: 1, Seq: 1, Side: Ask, Type: Limit, Price: 100, Qty: 5})
+	if aapl.Bids.Size() != 1 || msft.Asks.Size() != 1 || aapl.Asks.Size() != 0 {
+		t.Fatal("orders leaked across instruments")
+	}
+
+	if r := e.Cancel("MSFT", 1); r != RejectNone || msft.Asks.Size() != 0 {
+		t.Errorf("expected MSFT order cancelled, got %v", r)
+	}
+	if _, r := aapl.Lookup(1); r != RejectNone {
+		t.Error("cancelling in MSFT must not touch AAPL")
+	}
+	if _, r := e.Place("TSLA", OrderSpec{ID: 2, Side: Bid, Type: Limit, Price: 1, Qty: 1}); r != RejectUnknownSymbol {
+		t.Errorf("expected unknown symbol, got %v", r)
+	}
+	if r := e.Cancel("TSLA", 1); r != RejectUnknownSymbol {
+		t.Errorf("expected unknown symbol on cancel, got %v", r)
+	}
+	if got := e.Symbols(); len(got) != 2 || got[0] != "AAPL" || got[1] != "MSFT" {
+		t.Errorf("unexpected symbols %v", got)
+	}
+}
+
+func TestEngineSnapshotPerInstrument(t *testing.T) {
+	e := NewEngine(NewOrderPool(1<<10), newRetireRing(1<<10))
+	e.AddBook("AAPL")
+	e.AddBook("MSFT")
+	_, _ = e.Place("AAPL", OrderSpec{ID: 1, Seq: 1, Side: Bid, Type: Limit, Price: 100, Qty: 5})
+	_, _ = e.Place("MSFT", OrderSpec{ID: 2, Seq: 1, Side: Ask, Type: Limit, Price: 200, Qty: 5})
+	_, _ = e.Place("MSFT", OrderSpec{ID: 3, Seq: 2, Side: Ask, Type: Limit, Price: 201, Qty: 5})
+
+	count := 0
+	if r := e.Snapshot("MSFT", &Reader{}, func(p int64, o *Order) { count++ }); r != RejectNone {
+		t.Fatal(r)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 MSFT orders, got %d", count)
+	}
+}
+
+func TestEngineDedicatedPools(t *testing.T) {
+	shared := NewOrderPool(4)
+	e := NewEngine(shared, newRetireRing(16))
+	e.AddBook("AAPL")
+	own := NewOrderPool(1)
+	ownRing := newRetireRing(16)
+	e.AddBookWithPools("MSFT", own, ownRing)
+
+	_, _ = e.Place("MSFT", OrderSpec{ID: 1, Seq: 1, Side: Bid, Type: Limit, Price: 100, Qty: 5})
+	if _, r := e.Place("MSFT", OrderSpec{ID: 2, Seq: 2, Side: Bid, Type: Limit, Price: 100, Qty: 5}); r != RejectPoolExhausted {
+		t.Errorf("expected MSFT pool exhausted, got %v", r)
+	}
+	if _, r := e.Place("AAPL", OrderSpec{ID: 2, Seq: 1, Side: Bid, Type: Limit, Price: 100, Qty: 5}); r != RejectNone {
+		t.Errorf("AAPL must not be affected by MSFT pool, got %v", r)
+	}
+
+	// Cancelled MSFT order goes back to MSFT's own pool
+	_ = e.Cancel("MSFT", 1)
+	e.Reclaim()
+	if _, r := e.Place("MSFT", OrderSpec{ID: 3, Seq: 3, Side: Bid, Type: Limit, Price: 100, Qty: 5}); r != RejectNone {
+		t.Errorf("expected MSFT order accepted after reclaim, got %v", r)
+	}
+	if len(e.recyclers) != 2 {
+		t.Errorf("expected 2 pool/ring pairs, got %d", len(e.recyclers))
+	}
+}
+
+func TestEngineBackgroundReclaimer(t *testing.T) {
+	e := NewEngine(NewOrderPool(4), newRetireRing(16))
+	e.AddBook("AAPL")
+	rc := e.StartReclaimer(ReclaimerConfig{Interval: 100 * time.Microsecond})
+	defer rc.Stop()
+	own := NewOrderPool(1)
+	e.AddBookWithPools("MSFT", own, newRetireRing(16)) // listed after start
+
+	for id := uint64(1); id <= 50; id++ {
+		for {
+			_, r := e.Place("MSFT", OrderSpec{ID: id, Seq: id, Side: Bid, Type: Limit, Price: 100, Qty: 5})
+			if r == RejectNone {
+				break
+			}
+			if r != RejectPoolExhausted && r != RejectRetireRingFull {
+				t.Fatalf("place %d: %v", id, r)
+			}
+			e.Reclaim()
+			time.Sleep(10 * time.Microsecond)
+		}
+		_ = e.Cancel("MSFT", id)
+	}
+	waitFor(t, "MSFT order recycled", func() bool { return own.Available() == 1 })
+}
@@ -1,19 +1,88 @@
 package main
 
-import "sync/atomic"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 /************** Epoch bookkeeping (RCU-style) **************/
 
-var globalEpoch atomic.Uint64 // advanced by matcher only
+var globalEpoch atomic.Uint64 // advanced by the reclaiming side only
 
-type Reader struct{ epoch atomic.Uint64 } // 0 => not reading
+// Epochs start at 1: a Reader at 0 is not reading, so a read entered
+// before the first advance must not look idle.
+func init() { globalEpoch.Store(1) }
+
+// Reader is one goroutine's read-side epoch. A zero Reader is fine on the
+// matcher goroutine itself; any other goroutine must take one from
+// RegisterReader so reclamation waits for it.
+type Reader struct {
+	epoch atomic.Uint64 // 0 => not reading
+	_     [56]byte      // own cache line: busy readers never false-share
+}
 
 func (r *Reader) EnterRead() { r.epoch.Store(globalEpoch.Load()) }
 func (r *Reader) ExitRead()  { r.epoch.Store(0) }
 
-func minReaderEpoch(rs ...*Reader) uint64 {
+// ReaderRegistry is the set of readers reclamation waits for. Joining and
+// leaving take a lock and publish a new list; reclamation only loads it.
+type ReaderRegistry struct {
+	mu   sync.Mutex
+	list atomic.Pointer[[]*Reader]
+}
+
+// readers holds every registered reader of globalEpoch.
+var readers ReaderRegistry
+
+// RegisterReader returns a Reader that every reclamation pass consults
+// until UnregisterReader.
+func RegisterReader() *Reader { return readers.Register() }
+
+// UnregisterReader drops r once its goroutine is done reading.
+func UnregisterReader(r *Reader) { readers.Unregister(r) }
+
+func (g *ReaderRegistry) Register() *Reader {
+	r := &Reader{}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	old := g.load()
+	next := make([]*Reader, len(old), len(old)+1)
+	copy(next, old)
+	next = append(next, r)
+	g.list.Store(&next)
+	return r
+}
+
+// Unregister removes r, ending any read it left open.
+func (g *ReaderRegistry) Unregister(r *Reader) {
+	r.ExitRead()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	old := g.load()
+	next := make([]*Reader, 0, len(old))
+	for _, x := range old {
+		if x != r {
+			next = append(next, x)
+		}
+	}
+	g.list.Store(&next)
+}
+
+// Len returns the number of registered readers.
+func (g *ReaderRegistry) Len() int { return len(g.load()) }
+
+func (g *ReaderRegistry) load() []*Reader {
+	if p := g.list.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// minEpoch returns the oldest epoch a registered reader is in, or
+// ^uint64(0) when none is reading.
+func (g *ReaderRegistry) minEpoch() uint64 {
 	min := ^uint64(0)
-	for _, r := range rs {
+	for _, r := range g.load() {
 		e := r.epoch.Load()
 		if e != 0 && e < min {
 			min = e
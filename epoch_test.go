@@ -1,7 +1,10 @@
 package main
 
 import (
+	"math/rand"
+	"sync"
 	"testing"
+	"unsafe"
 )
 
 func TestReaderEpochFlow(t *testing.T) {
@@ -17,3 +20,122 @@ func TestReaderEpochFlow(t *testing.T) {
 		t.Errorf("expected epoch=0 after ExitRead, got %d", r.epoch.Load())
 	}
 }
+
+func TestReaderOwnsCacheLine(t *testing.T) {
+	if n := unsafe.Sizeof(Reader{}); n%64 != 0 {
+		t.Errorf("Reader is %d bytes; neighbours would share a cache line", n)
+	}
+}
+
+func TestRegistryMinEpoch(t *testing.T) {
+	var g ReaderRegistry
+	if g.minEpoch() != ^uint64(0) {
+		t.Fatal("empty registry must not hold anything back")
+	}
+	globalEpoch.Store(20)
+	a, b := g.Register(), g.Register()
+	a.EnterRead()
+	globalEpoch.Store(25)
+	b.EnterRead()
+	if got := g.minEpoch(); got != 20 {
+		t.Errorf("min epoch = %d, want 20", got)
+	}
+	g.Unregister(a) // leaves mid-read
+	if got := g.minEpoch(); got != 25 {
+		t.Errorf("min epoch after leave = %d, want 25", got)
+	}
+	b.ExitRead()
+	if g.minEpoch() != ^uint64(0) || g.Len() != 1 {
+		t.Errorf("idle reader held epoch back or was dropped (len %d)", g.Len())
+	}
+	g.Unregister(b)
+	if g.Len() != 0 {
+		t.Errorf("expected empty registry, got %d", g.Len())
+	}
+}
+
+// A reader joining mid-epoch holds back what was retired while it reads;
+// leaving releases it without the matcher being told.
+func TestReaderJoinAndLeaveMidEpoch(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	free := pool.Available()
+
+	_, _ = book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq)
+	advanceEpochAndReclaim(rq, pool)
+
+	r := RegisterReader()
+	defer UnregisterReader(r)
+	r.EnterRead()
+	_ = book.CancelByID(1, rq)
+	advanceEpochAndReclaim(rq, pool)
+	if pool.Available() != free-1 {
+		t.Fatal("order recycled under an active reader")
+	}
+
+	late := RegisterReader() // joins after the retire: cannot see the order
+	late.EnterRead()
+	r.ExitRead()
+	advanceEpochAndReclaim(rq, pool)
+	if pool.Available() != free {
+		t.Error("late reader held back an order retired before it joined")
+	}
+
+	_, _ = book.placeOrder(Bid, Limit, 100, 2, 5, 2, pool, rq)
+	_ = book.CancelByID(2, rq)
+	advanceEpochAndReclaim(rq, pool)
+	if pool.Available() != free-1 {
+		t.Fatal("order recycled under the late reader")
+	}
+	UnregisterReader(late) // leaves mid-read
+	advanceEpochAndReclaim(rq, pool)
+	if pool.Available() != free {
+		t.Error("unregistered reader still holds back reclamation")
+	}
+}
+
+// Dozens of snapshot goroutines come and go while the matcher churns and
+// reclaims. Run with -race.
+func TestReadersComeAndGoDuringChurn(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan string, 32)
+
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				r := RegisterReader()
+				for k := 0; k < 3; k++ {
+					r.EnterRead()
+					msg := checkView(book.view.Load())
+					r.ExitRead()
+					if msg != "" {
+						errs <- msg
+						UnregisterReader(r)
+						return
+					}
+				}
+				UnregisterReader(r)
+			}
+		}()
+	}
+
+	rng := rand.New(rand.NewSource(13))
+	churn(book, pool, rq, rng, 1, 20_000)
+	close(stop)
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Error(msg)
+	}
+	if readers.Len() != 0 {
+		t.Errorf("%d readers left registered", readers.Len())
+	}
+}
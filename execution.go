@@ -0,0 +1,68 @@
+package main
+
+// Execution is a single fill between an incoming (aggressor) order and a
+// resting order. Price is always the price of the resting level. Auction
+// fills are between two resting orders at the uncross price; the buyer is
+// reported as the aggressor.
+type Execution struct {
+	Seq           uint64 // monotonic per book, starts at 1
+	AggressorID   uint64
+	RestingID     uint64
+	Price         int64
+	Qty           int64
+	AggressorSide Side
+	AggressorDone bool // aggressor has no quantity left after this fill
+	RestingDone   bool // resting order has no quantity left after this fill
+	Auction       bool // uncross fill
+}
+
+// ExecutionSink receives executions in match order.
+// Called on the matcher thread; implementations must not block.
+type ExecutionSink interface {
+	OnExecution(e Execution)
+}
+
+// ExecutionSinkFunc adapts a plain function to ExecutionSink.
+type ExecutionSinkFunc func(e Execution)
+
+func (f ExecutionSinkFunc) OnExecution(e Execution) { f(e) }
+
+// SetExecutionSink installs the sink for trade reports (nil disables).
+func (b *OrderBook) SetExecutionSink(s ExecutionSink) { b.sink = s }
+
+// emitExecution stamps the next execution sequence and reports the fill.
+func (b *OrderBook) emitExecution(aggr, rest *Order, price, qty int64) {
+	b.execSeq++
+	if b.sink == nil {
+		return
+	}
+	b.sink.OnExecution(Execution{
+		Seq:           b.execSeq,
+		AggressorID:   aggr.ID,
+		RestingID:     rest.ID,
+		Price:         price,
+		Qty:           qty,
+		AggressorSide: aggr.Side,
+		AggressorDone: aggr.Qty == 0,
+		RestingDone:   rest.Qty == 0 && rest.hidden == 0,
+	})
+}
+
+// emitAuctionExecution reports an uncross fill between two resting orders.
+func (b *OrderBook) emitAuctionExecution(bid, ask *Order, price, qty int64) {
+	b.execSeq++
+	if b.sink == nil {
+		return
+	}
+	b.sink.OnExecution(Execution{
+		Seq:           b.execSeq,
+		AggressorID:   bid.ID,
+		RestingID:     ask.ID,
+		Price:         price,
+		Qty:           qty,
+		AggressorSide: Bid,
+		AggressorDone: bid.Qty == 0 && bid.hidden == 0,
+		RestingDone:   ask.Qty == 0 && ask.hidden == 0,
+		Auction:       true,
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+type execRecorder struct{ execs []Execution }
+
+func (r *execRecorder) OnExecution(e Execution) { r.execs = append(r.execs, e) }
+
+func TestExecutionReportsSweep(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	rec := &execRecorder{}
+	book.SetExecutionSink(rec)
+
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 2, 5, 2, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 101, 3, 5, 3, pool, rq)
+
+	bid, _ := book.placeOrder(Bid, Limit, 101, 4, 12, 4, pool, rq)
+	if bid.Filled != 12 {
+		t.Fatalf("expected aggressor filled=12, got %d", bid.Filled)
+	}
+
+	want := []Execution{
+		{Seq: 1, AggressorID: 4, RestingID: 1, Price: 100, Qty: 5, AggressorSide: Bid, RestingDone: true},
+		{Seq: 2, AggressorID: 4, RestingID: 2, Price: 100, Qty: 5, AggressorSide: Bid, RestingDone: true},
+		{Seq: 3, AggressorID: 4, RestingID: 3, Price: 101, Qty: 2, AggressorSide: Bid, AggressorDone: true},
+	}
+	if len(rec.execs) != len(want) {
+		t.Fatalf("expected %d executions, got %d", len(want), len(rec.execs))
+	}
+	for i := range want {
+		if rec.execs[i] != want[i] {
+			t.Errorf("execution %d: got %+v, want %+v", i, rec.execs[i], want[i])
+		}
+	}
+
+	lvl := book.Asks.FindLevel(101)
+	if lvl == nil || lvl.TotalQty != 3 {
+		t.Errorf("expected level 101 TotalQty=3 after partial fill, got %+v", lvl)
+	}
+}
+
+func TestExecutionReportsAskAggressor(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	var got []Execution
+	book.SetExecutionSink(ExecutionSinkFunc(func(e Execution) { got = append(got, e) }))
+
+	_, _ = book.placeOrder(Bid, Limit, 99, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 100, 2, 5, 2, pool, rq)
+	_, _ = book.placeOrder(Ask, Market, 0, 3, 7, 3, pool, rq)
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 executions, got %d", len(got))
+	}
+	if got[0].RestingID != 2 || got[0].Price != 100 || got[0].Qty != 5 {
+		t.Errorf("expected best bid hit first, got %+v", got[0])
+	}
+	if got[1].RestingID != 1 || got[1].Price != 99 || got[1].Qty != 2 || !got[1].AggressorDone {
+		t.Errorf("unexpected second execution %+v", got[1])
+	}
+	if got[1].AggressorSide != Ask {
+		t.Errorf("expected aggressor side Ask, got %d", got[1].AggressorSide)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+// ---------------- Time-in-force expiry ---------------- //
+//
+// GTD orders are scheduled in a min-heap on ExpireAt. Entries are not
+// removed when an order finishes early; a popped entry is only acted on if
+// the ID index still maps it to the same live order, and that order is
+// still a GTD due at the entry's time (pooled memory and IDs get reused, so
+// the pointer alone does not say it is the order that was scheduled). DAY orders are not
+// scheduled: EndOfDay sweeps the index once and cancels them all.
+// Expiry always completes: orders are removed directly rather than through
+// cancelOrder, so a full retire ring never holds them back (retire parks
+// what does not fit). How much a pass expires depends only on the clock.
+
+type expiryEntry struct {
+	at int64
+	id uint64
+	o  *Order
+}
+
+type expiryHeap []expiryEntry
+
+func (h *expiryHeap) push(e expiryEntry) {
+	*h = append(*h, e)
+	s := *h
+	for i := len(s) - 1; i > 0; {
+		p := (i - 1) / 2
+		if s[p].at <= s[i].at {
+			break
+		}
+		s[p], s[i] = s[i], s[p]
+		i = p
+	}
+}
+
+func (h *expiryHeap) pop() expiryEntry {
+	s := *h
+	top := s[0]
+	n := len(s) - 1
+	s[0] = s[n]
+	s[n] = expiryEntry{}
+	s = s[:n]
+	for i := 0; ; {
+		l, r, m := 2*i+1, 2*i+2, i
+		if l < n && s[l].at < s[m].at {
+			m = l
+		}
+		if r < n && s[r].at < s[m].at {
+			m = r
+		}
+		if m == i {
+			break
+		}
+		s[m], s[i] = s[i], s[m]
+		i = m
+	}
+	*h = s
+	return top
+}
+
+// SetClock replaces the wall clock (tests, replay).
+func (b *OrderBook) SetClock(c Clock) { b.clock = c }
+
+// scheduleExpiry registers a live GTD order with the scheduler.
+func (b *OrderBook) scheduleExpiry(o *Order) {
+	if o.TIF == GTD && o.Status == Active {
+		b.expiries.push(expiryEntry{at: o.ExpireAt, id: o.ID, o: o})
+	}
+}
+
+// ExpireOrders cancels every GTD order due at the current clock time.
+// Returns the number of orders expired.
+func (b *OrderBook) ExpireOrders(rq *retireRing) int {
+	now := b.clock.Now()
+	n := 0
+	for len(b.expiries) > 0 && b.expiries[0].at <= now {
+		e := b.expiries.pop()
+		if o, _ := b.Lookup(e.id); o != e.o || o.TIF != GTD || o.ExpireAt != e.at {
+			continue // already filled or cancelled
+		}
+		b.remove(e.o.Price, e.o, rq, e.o.Side)
+		n++
+	}
+	return n
+}
+
+// EndOfDay cancels all live DAY orders (resting and pending stops) in one
+// pass over the ID index. Returns the number of orders expired.
+func (b *OrderBook) EndOfDay(rq *retireRing) int {
+	n := 0
+	for i := range b.index.slots {
+		s := &b.index.slots[i]
+		if s.state != slotLive || s.o.TIF != DAY {
+			continue
+		}
+		b.remove(s.o.Price, s.o, rq, s.o.Side)
+		n++
+	}
+	return n
+}
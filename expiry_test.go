@@ -0,0 +1,161 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newClockedEnv(start int64) (*OrderBook, *OrderPool, *retireRing, *ManualClock) {
+	book, pool, rq := newTestEnv()
+	clk := NewManualClock(start)
+	book.SetClock(clk)
+	return book, pool, rq, clk
+}
+
+func TestGTDExpiresOnSchedule(t *testing.T) {
+	book, pool, rq, clk := newClockedEnv(1_000)
+	gtd, r := book.submit(OrderSpec{ID: 1, Seq: 1, Side: Bid, Type: Limit, Price: 100, Qty: 5, TIF: GTD, ExpireAt: 2_000}, pool, rq)
+	if r != RejectNone {
+		t.Fatalf("expected GTD accepted, got %v", r)
+	}
+	gtc, _ := book.placeOrder(Bid, Limit, 100, 2, 5, 2, pool, rq)
+
+	clk.Set(1_999)
+	if n := book.ExpireOrders(rq); n != 0 || gtd.Status != Active {
+		t.Fatal("GTD expired early")
+	}
+	clk.Set(2_000)
+	if n := book.ExpireOrders(rq); n != 1 || gtd.Status != Inactive {
+		t.Fatalf("expected GTD expired at its deadline, got n=%d", n)
+	}
+	if lvl := book.Bids.FindLevel(100); lvl == nil || lvl.head != gtc || lvl.TotalQty != 5 {
+		t.Error("expected only the GTC order left resting")
+	}
+	if _, r := book.Lookup(1); r != RejectAlreadyDone {
+		t.Errorf("expired order must be done in the index, got %v", r)
+	}
+}
+
+func TestExpiredGTDDoesNotTrade(t *testing.T) {
+	book, pool, rq, clk := newClockedEnv(0)
+	_, _ = book.submit(OrderSpec{ID: 1, Seq: 1, Side: Ask, Type: Limit, Price: 100, Qty: 5, TIF: GTD, ExpireAt: int64(time.Second)}, pool, rq)
+
+	clk.Advance(2 * time.Second)
+	bid, _ := book.placeOrder(Bid, Limit, 100, 2, 5, 2, pool, rq)
+	if bid.Filled != 0 || bid.Status != Active {
+		t.Error("incoming order must not trade against an expired GTD order")
+	}
+}
+
+func TestGTDFilledBeforeExpiryIsSkipped(t *testing.T) {
+	book, pool, rq, clk := newClockedEnv(0)
+	_, _ = book.submit(OrderSpec{ID: 1, Seq: 1, Side: Ask, Type: Limit, Price: 100, Qty: 5, TIF: GTD, ExpireAt: 10}, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 100, 2, 5, 2, pool, rq)
+
+	// Recycle the filled order so a new order reuses its memory
+	advanceEpochAndReclaim(rq, pool)
+	_, _ = book.placeOrder(Ask, Limit, 101, 3, 5, 3, pool, rq)
+
+	clk.Set(10)
+	if n := book.ExpireOrders(rq); n != 0 {
+		t.Errorf("expected stale expiry entry ignored, got %d expired", n)
+	}
+	if book.Asks.FindLevel(101) == nil {
+		t.Error("recycled order must not be expired by a stale entry")
+	}
+}
+
+// A new GTC order reusing both the ID and the memory of a filled GTD order
+// must not be expired by the old entry.
+func TestGTDEntryIgnoresReusedIDAndMemory(t *testing.T) {
+	book, pool, rq, clk := newClockedEnv(0)
+	gtd, _ := book.submit(OrderSpec{ID: 1, Seq: 1, Side: Ask, Type: Limit, Price: 100, Qty: 5, TIF: GTD, ExpireAt: 10}, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 100, 2, 5, 2, pool, rq)
+	advanceEpochAndReclaim(rq, pool)
+
+	_, _ = book.placeOrder(Ask, Limit, 102, 9, 5, 3, pool, rq) // takes the aggressor's memory
+	gtc, _ := book.placeOrder(Ask, Limit, 101, 1, 5, 4, pool, rq)
+	if gtc != gtd {
+		t.Fatal("test needs the pool to hand back the filled order's memory")
+	}
+	clk.Set(10)
+	if n := book.ExpireOrders(rq); n != 0 || gtc.Status != Active {
+		t.Errorf("stale entry expired the reused order (%d expired)", n)
+	}
+}
+
+func TestGTDRejectsPastExpiry(t *testing.T) {
+	book, pool, rq, _ := newClockedEnv(100)
+	if _, r := book.submit(OrderSpec{ID: 1, Side: Bid, Type: Limit, Price: 100, Qty: 5, TIF: GTD, ExpireAt: 100}, pool, rq); r != RejectInvalidExpiry {
+		t.Errorf("expected invalid expiry, got %v", r)
+	}
+}
+
+func TestUnknownTIFRejected(t *testing.T) {
+	book, pool, rq, _ := newClockedEnv(0)
+	if _, r := book.submit(OrderSpec{ID: 1, Side: Bid, Type: Limit, Price: 100, Qty: 5, TIF: GTD + 1}, pool, rq); r != RejectInvalidTIF {
+		t.Errorf("expected invalid TIF, got %v", r)
+	}
+	if book.Bids.MaxLevel() != nil {
+		t.Error("rejected order rests")
+	}
+}
+
+func TestEndOfDayExpiresDayOrders(t *testing.T) {
+	book, pool, rq, _ := newClockedEnv(0)
+	day1, _ := book.submit(OrderSpec{ID: 1, Seq: 1, Side: Bid, Type: Limit, Price: 99, Qty: 5, TIF: DAY}, pool, rq)
+	day2, _ := book.submit(OrderSpec{ID: 2, Seq: 2, Side: Ask, Type: Limit, Price: 101, Qty: 5, TIF: DAY}, pool, rq)
+	stop, _ := book.submit(OrderSpec{ID: 3, Seq: 3, Side: Bid, Type: Stop, StopPrice: 110, Qty: 5, TIF: DAY}, pool, rq)
+	gtc, _ := book.placeOrder(Bid, Limit, 98, 4, 5, 4, pool, rq)
+
+	if n := book.EndOfDay(rq); n != 3 {
+		t.Fatalf("expected 3 DAY orders expired, got %d", n)
+	}
+	for _, o := range []*Order{day1, day2, stop} {
+		if o.Status != Inactive {
+			t.Errorf("order %d should have expired", o.ID)
+		}
+	}
+	if gtc.Status != Active || book.Bids.Size() != 1 || book.Asks.Size() != 0 || book.buyStops.Size() != 0 {
+		t.Error("expected only the GTC order left")
+	}
+}
+
+func TestExpiryHeapOrder(t *testing.T) {
+	var h expiryHeap
+	for _, at := range []int64{5, 1, 4, 2, 3} {
+		h.push(expiryEntry{at: at})
+	}
+	for want := int64(1); want <= 5; want++ {
+		if got := h.pop().at; got != want {
+			t.Fatalf("expected %d, got %d", want, got)
+		}
+	}
+}
+
+// Expiry must not depend on retire ring space: a tiny ring still sees every
+// due order expire in one pass, the overflow parked until it drains.
+func TestExpiryCompletesWithFullRing(t *testing.T) {
+	book, pool := NewOrderBook(), NewOrderPool(64)
+	rq := newRetireRing(4)
+	clk := NewManualClock(0)
+	book.SetClock(clk)
+	for i := uint64(1); i <= 6; i++ {
+		_, _ = book.submit(OrderSpec{ID: i, Seq: i, Side: Bid, Type: Limit, Price: int64(90 + i), Qty: 5, TIF: DAY}, pool, rq)
+		_, _ = book.submit(OrderSpec{ID: 10 + i, Seq: 10 + i, Side: Ask, Type: Limit, Price: int64(110 + i), Qty: 5, TIF: GTD, ExpireAt: 10}, pool, rq)
+	}
+	if n := book.EndOfDay(rq); n != 6 || book.Bids.Size() != 0 {
+		t.Errorf("end of day expired %d, %d bid levels left", n, book.Bids.Size())
+	}
+	clk.Set(10)
+	if n := book.ExpireOrders(rq); n != 6 || book.Asks.Size() != 0 {
+		t.Errorf("GTD pass expired %d, %d ask levels left", n, book.Asks.Size())
+	}
+	for i := 0; i < 4; i++ {
+		book.retireReady(rq)
+		advanceEpochAndReclaim(rq, pool)
+	}
+	if len(book.parked) != 0 || pool.Available() != 64 {
+		t.Errorf("%d still parked, %d of 64 back in the pool", len(book.parked), pool.Available())
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import "testing"
+
+func placeIceberg(book *OrderBook, side Side, price int64, id uint64, qty, display int64, pool *OrderPool, rq *retireRing) (*Order, RejectReason) {
+	return book.submit(OrderSpec{
+		ID: id, Seq: id, Side: side, Type: Limit, Price: price, Qty: qty, Display: display,
+	}, pool, rq)
+}
+
+func TestIcebergShowsOnlyDisplay(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	ice, r := placeIceberg(book, Ask, 100, 1, 10, 3, pool, rq)
+	if r != RejectNone {
+		t.Fatalf("expected iceberg accepted, got %v", r)
+	}
+	lvl := book.Asks.FindLevel(100)
+	if lvl.TotalQty != 3 || lvl.HiddenQty != 7 || ice.Qty != 3 {
+		t.Errorf("expected displayed=3 hidden=7, got total=%d hidden=%d qty=%d", lvl.TotalQty, lvl.HiddenQty, ice.Qty)
+	}
+
+	var shown int64
+	book.SnapshotActiveIter(&Reader{}, func(p int64, o *Order) { shown += o.Qty })
+	if shown != 3 {
+		t.Errorf("snapshot must expose only the displayed slice, got %d", shown)
+	}
+}
+
+func TestIcebergReplenishLosesPriority(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	ice, _ := placeIceberg(book, Ask, 100, 1, 10, 3, pool, rq)
+	plain, _ := book.placeOrder(Ask, Limit, 100, 2, 5, 2, pool, rq)
+
+	_, _ = book.placeOrder(Bid, Limit, 100, 3, 3, 3, pool, rq)
+
+	lvl := book.Asks.FindLevel(100)
+	if lvl.head != plain || lvl.tail != ice {
+		t.Fatal("replenished iceberg must go to the back of the queue")
+	}
+	if ice.Qty != 3 || ice.hidden != 4 || lvl.TotalQty != 8 || lvl.HiddenQty != 4 {
+		t.Errorf("unexpected refresh state qty=%d hidden=%d total=%d levelHidden=%d",
+			ice.Qty, ice.hidden, lvl.TotalQty, lvl.HiddenQty)
+	}
+
+	// Next aggressor hits the plain order first
+	_, _ = book.placeOrder(Bid, Limit, 100, 4, 4, 4, pool, rq)
+	if plain.Qty != 1 || ice.Qty != 3 {
+		t.Errorf("expected plain order to trade ahead, got plain=%d ice=%d", plain.Qty, ice.Qty)
+	}
+}
+
+func TestIcebergFOKCountsReserve(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	rec := &execRecorder{}
+	book.SetExecutionSink(rec)
+	ice, _ := placeIceberg(book, Ask, 100, 1, 10, 3, pool, rq)
+
+	fok, _ := book.placeOrder(Bid, FOK, 100, 2, 10, 2, pool, rq)
+	if fok.Filled != 10 {
+		t.Fatalf("expected FOK filled from displayed+reserve, got %d", fok.Filled)
+	}
+	if len(rec.execs) != 4 {
+		t.Fatalf("expected 4 slices (3,3,3,1), got %d", len(rec.execs))
+	}
+	for i, e := range rec.execs {
+		if done := i == 3; e.RestingDone != done {
+			t.Errorf("slice %d: RestingDone=%v, want %v", i, e.RestingDone, done)
+		}
+	}
+	if ice.Status != Inactive || book.Asks.Size() != 0 {
+		t.Error("expected exhausted iceberg removed")
+	}
+
+	// Not enough even with reserve → killed
+	_, _ = placeIceberg(book, Ask, 100, 3, 4, 2, pool, rq)
+	if fok2, _ := book.placeOrder(Bid, FOK, 100, 4, 5, 4, pool, rq); fok2.Filled != 0 {
+		t.Errorf("expected FOK kill, got filled=%d", fok2.Filled)
+	}
+}
+
+func TestIcebergAggressorRestsDisplay(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 4, 1, pool, rq)
+
+	ice, _ := placeIceberg(book, Bid, 100, 2, 10, 2, pool, rq)
+	if ice.Filled != 4 {
+		t.Fatalf("aggressive iceberg should trade its full size, got %d", ice.Filled)
+	}
+	if ice.Qty != 2 || ice.hidden != 4 {
+		t.Errorf("expected remainder split 2 shown / 4 hidden, got %d / %d", ice.Qty, ice.hidden)
+	}
+
+	// Amend total open qty down: reserve is consumed first, position kept
+	if res, _ := book.Amend(2, 100, 3, 3, rq); res != AmendedInPlace || ice.Qty != 2 || ice.hidden != 1 {
+		t.Errorf("expected reserve cut to 1, got %v qty=%d hidden=%d", res, ice.Qty, ice.hidden)
+	}
+}
+
+func TestIcebergInvalidDisplay(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	if _, r := placeIceberg(book, Bid, 100, 1, 5, 6, pool, rq); r != RejectInvalidDisplay {
+		t.Errorf("expected display > qty rejected, got %v", r)
+	}
+	if _, r := book.submit(OrderSpec{ID: 2, Side: Bid, Type: IOC, Price: 100, Qty: 5, Display: 1}, pool, rq); r != RejectInvalidDisplay {
+		t.Errorf("expected iceberg IOC rejected, got %v", r)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import "strconv"
+
+// Instrument is the reference data a book enforces on every place/amend.
+// Prices and quantities stay integers; PriceScale says how many of the
+// price digits are decimals (scale 2: 10050 renders as "100.50").
+type Instrument struct {
+	Symbol     string
+	Currency   string
+	PriceScale uint8
+	TickSize   int64 // price increment
+	LotSize    int64 // quantity increment
+	MinQty     int64 // 0 = one lot
+	MaxQty     int64 // 0 = unlimited
+	MinPrice   int64 // static price band, 0 = open
+	MaxPrice   int64 // static price band, 0 = open
+}
+
+// normalized fills in neutral defaults for unset increments.
+func (in Instrument) normalized() Instrument {
+	if in.TickSize <= 0 {
+		in.TickSize = 1
+	}
+	if in.LotSize <= 0 {
+		in.LotSize = 1
+	}
+	return in
+}
+
+// checkQty enforces lot size and min/max order quantity.
+func (in *Instrument) checkQty(qty int64) RejectReason {
+	if qty <= 0 {
+		return RejectInvalidQty
+	}
+	if qty%in.LotSize != 0 {
+		return RejectOffLot
+	}
+	if qty < in.MinQty || (in.MaxQty > 0 && qty > in.MaxQty) {
+		return RejectQtyOutOfRange
+	}
+	return RejectNone
+}
+
+// checkPrice enforces tick size and the static price band.
+func (in *Instrument) checkPrice(price int64) RejectReason {
+	if price <= 0 {
+		return RejectInvalidPrice
+	}
+	if price%in.TickSize != 0 {
+		return RejectOffTick
+	}
+	if price < in.MinPrice || (in.MaxPrice > 0 && price > in.MaxPrice) {
+		return RejectPriceOutOfRange
+	}
+	return RejectNone
+}
+
+// FormatPrice renders an integer price with the instrument's decimals.
+func (in *Instrument) FormatPrice(p int64) string {
+	if in.PriceScale == 0 {
+		return strconv.FormatInt(p, 10)
+	}
+	neg := p < 0
+	if neg {
+		p = -p
+	}
+	s := strconv.FormatInt(p, 10)
+	n := int(in.PriceScale)
+	for len(s) <= n {
+		s = "0" + s
+	}
+	s = s[:len(s)-n] + "." + s[len(s)-n:]
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// NewOrderBookFor creates a book enforcing 'inst'.
+func NewOrderBookFor(inst Instrument) *OrderBook {
+	b := NewOrderBook()
+	b.inst = inst.normalized()
+	return b
+}
+
+// Instrument returns the reference data the book enforces.
+func (b *OrderBook) Instrument() *Instrument { return &b.inst }
@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+func newInstrumentEnv() (*OrderBook, *OrderPool, *retireRing) {
+	book := NewOrderBookFor(Instrument{
+		Symbol: "TEST", Currency: "USD", PriceScale: 2,
+		TickSize: 5, LotSize: 10, MinQty: 20, MaxQty: 1000,
+		MinPrice: 50_00, MaxPrice: 200_00,
+	})
+	return book, NewOrderPool(1 << 10), newRetireRing(1 << 10)
+}
+
+func TestInstrumentRejectsOnPlace(t *testing.T) {
+	book, pool, rq := newInstrumentEnv()
+
+	cases := []struct {
+		price, qty int64
+		want       RejectReason
+	}{
+		{100_00, 100, RejectNone},
+		{100_03, 100, RejectOffTick},
+		{100_00, 105, RejectOffLot},
+		{100_00, 10, RejectQtyOutOfRange},
+		{100_00, 1010, RejectQtyOutOfRange},
+		{45_00, 100, RejectPriceOutOfRange},
+		{200_05, 100, RejectPriceOutOfRange},
+	}
+	for i, c := range cases {
+		_, r := book.placeOrder(Bid, Limit, c.price, uint64(i+1), c.qty, uint64(i+1), pool, rq)
+		if r != c.want {
+			t.Errorf("case %d (%d x %d): expected %v, got %v", i, c.qty, c.price, c.want, r)
+		}
+	}
+
+	// Market orders skip price checks but not lot rules
+	if _, r := book.placeOrder(Ask, Market, 0, 100, 25, 100, pool, rq); r != RejectOffLot {
+		t.Errorf("expected off-lot market order rejected, got %v", r)
+	}
+	if _, r := book.submit(OrderSpec{ID: 101, Side: Bid, Type: Stop, StopPrice: 150_01, Qty: 20}, pool, rq); r != RejectOffTick {
+		t.Errorf("expected off-tick stop price rejected, got %v", r)
+	}
+	if _, r := book.submit(OrderSpec{ID: 102, Side: Bid, Type: Limit, Price: 100_00, Qty: 100, Display: 15}, pool, rq); r != RejectOffLot {
+		t.Errorf("expected off-lot display rejected, got %v", r)
+	}
+}
+
+func TestInstrumentRejectsOnAmend(t *testing.T) {
+	book, pool, rq := newInstrumentEnv()
+	_, _ = book.placeOrder(Bid, Limit, 100_00, 1, 100, 1, pool, rq)
+
+	if _, r := book.Amend(1, 100_01, 100, 2, rq); r != RejectOffTick {
+		t.Errorf("expected off-tick amend rejected, got %v", r)
+	}
+	if _, r := book.Amend(1, 100_00, 55, 2, rq); r != RejectOffLot {
+		t.Errorf("expected off-lot amend rejected, got %v", r)
+	}
+	if res, r := book.Amend(1, 100_05, 50, 2, rq); res != AmendRequeued || r != RejectNone {
+		t.Errorf("expected valid amend accepted, got %v %v", res, r)
+	}
+}
+
+func TestInstrumentTickDrivesPostOnlySlide(t *testing.T) {
+	book, pool, rq := newInstrumentEnv()
+	_, _ = book.placeOrder(Ask, Limit, 100_00, 1, 100, 1, pool, rq)
+	bid, _ := book.placeOrder(Bid, PostOnlySlide, 101_00, 2, 100, 2, pool, rq)
+	if bid.Price != 99_95 {
+		t.Errorf("expected slide one 5-cent tick behind, got %s", book.Instrument().FormatPrice(bid.Price))
+	}
+}
+
+func TestInstrumentBandAppliesToSlidPrice(t *testing.T) {
+	book, pool, rq := newInstrumentEnv()
+	_, _ = book.placeOrder(Ask, Limit, 50_00, 1, 100, 1, pool, rq)
+	if _, r := book.placeOrder(Bid, PostOnlySlide, 60_00, 2, 100, 2, pool, rq); r != RejectPriceOutOfRange {
+		t.Errorf("expected slide below MinPrice rejected, got %v", r)
+	}
+	if book.Bids.MaxLevel() != nil {
+		t.Error("bid rested below the instrument's band")
+	}
+}
+
+func TestFormatPrice(t *testing.T) {
+	cases := []struct {
+		scale uint8
+		p     int64
+		want  string
+	}{
+		{0, 100, "100"},
+		{2, 10050, "100.50"},
+		{2, 5, "0.05"},
+		{4, 12345, "1.2345"},
+		{2, -150, "-1.50"},
+	}
+	for _, c := range cases {
+		in := Instrument{PriceScale: c.scale}
+		if got := in.FormatPrice(c.p); got != c.want {
+			t.Errorf("FormatPrice(%d, scale %d) = %q, want %q", c.p, c.scale, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// ---------------- Write-ahead command journal ---------------- //
+//
+// Layout (little endian): a file header, then records.
+//
+//	magic "OBJL" | version u16
+//	[len u32][crc u32][seq u64][kind u8][time i64][payload]
+//
+// 'len' counts everything after the crc field, 'crc' is CRC-32C over the
+// same bytes. Sequence numbers start at 1 and have no gaps. Bump
+// journalVersion whenever a record layout changes (version 1 is the first
+// with a header; place payloads carry Protect).
+
+type FsyncPolicy uint8
+
+const (
+	FsyncEvery FsyncPolicy = iota // fsync after every record
+	FsyncBatch                    // fsync every N records
+	FsyncNone                     // leave flushing to the OS
+)
+
+var (
+	ErrJournalCorrupt = errors.New("journal: crc mismatch or bad record")
+	ErrJournalTorn    = errors.New("journal: torn record at tail")
+	ErrJournalFormat  = errors.New("journal: no header or unsupported version")
+	ErrReplayDiverged = errors.New("journal: replayed command rejected for lack of resources")
+)
+
+var (
+	crcTable     = crc32.MakeTable(crc32.Castagnoli)
+	journalMagic = [4]byte{'O', 'B', 'J', 'L'}
+)
+
+const (
+	journalVersion = 1
+	journalHdrLen  = 4 + 2
+	recHeader      = 8  // len + crc
+	recFixed       = 17 // seq + kind + time
+	placeLen       = 8 + 8 + 8 + 1 + 1 + 8 + 8 + 8 + 8 + 1 + 8 + 8
+	amendLen       = 8 + 8 + 8 + 8
+	cancelLen      = 8
+	sessionLen     = 1
+	maxRecordSz    = recHeader + recFixed + placeLen
+)
+
+// syncer is implemented by *os.File.
+type syncer interface{ Sync() error }
+
+// Journal appends commands to w. Not safe for concurrent use.
+type Journal struct {
+	w       io.Writer
+	policy  FsyncPolicy
+	batch   int // FsyncBatch: records per fsync
+	pending int
+	seq     uint64
+	started bool // file header written
+	buf     [maxRecordSz]byte
+}
+
+// NewJournal appends after record 'lastSeq' (0 for a new journal, which
+// gets its file header with the first record).
+func NewJournal(w io.Writer, policy FsyncPolicy, batch int, lastSeq uint64) *Journal {
+	if batch <= 0 {
+		batch = 1
+	}
+	return &Journal{w: w, policy: policy, batch: batch, seq: lastSeq, started: lastSeq != 0}
+}
+
+// Seq returns the sequence number of the last appended record.
+func (j *Journal) Seq() uint64 { return j.seq }
+
+// Append writes c as the next record and syncs per policy.
+func (j *Journal) Append(c Command) (uint64, error) {
+	if !j.started {
+		var hdr [journalHdrLen]byte
+		copy(hdr[:], journalMagic[:])
+		binary.LittleEndian.PutUint16(hdr[4:], journalVersion)
+		if _, err := j.w.Write(hdr[:]); err != nil {
+			return 0, err
+		}
+		j.started = true
+	}
+	n := encodeCommand(j.buf[recHeader:], j.seq+1, c)
+	body := j.buf[recHeader : recHeader+n]
+	binary.LittleEndian.PutUint32(j.buf[0:], uint32(n))
+	binary.LittleEndian.PutUint32(j.buf[4:], crc32.Checksum(body, crcTable))
+	if _, err := j.w.Write(j.buf[:recHeader+n]); err != nil {
+		return 0, err
+	}
+	j.seq++
+
+	j.pending++
+	if j.policy == FsyncEvery || (j.policy == FsyncBatch && j.pending >= j.batch) {
+		return j.seq, j.Sync()
+	}
+	return j.seq, nil
+}
+
+// Sync forces pending records to stable storage (if w supports it).
+func (j *Journal) Sync() error {
+	j.pending = 0
+	if s, ok := j.w.(syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+func encodeCommand(p []byte, seq uint64, c Command) int {
+	le := binary.LittleEndian
+	le.PutUint64(p[0:], seq)
+	p[8] = byte(c.Kind)
+	le.PutUint64(p[9:], uint64(c.Time))
+	q := p[recFixed:]
+	switch c.Kind {
+	case CmdPlace:
+		s := c.Order
+		le.PutUint64(q[0:], s.ID)
+		le.PutUint64(q[8:], s.Seq)
+		le.PutUint64(q[16:], s.Account)
+		q[24] = byte(s.Side)
+		q[25] = byte(s.Type)
+		le.PutUint64(q[26:], uint64(s.Price))
+		le.PutUint64(q[34:], uint64(s.Qty))
+		le.PutUint64(q[42:], uint64(s.StopPrice))
+		le.PutUint64(q[50:], uint64(s.Display))
+		q[58] = byte(s.TIF)
+		le.PutUint64(q[59:], uint64(s.ExpireAt))
+		le.PutUint64(q[67:], uint64(s.Protect))
+		return recFixed + placeLen
+	case CmdCancel:
+		le.PutUint64(q[0:], c.ID)
+		return recFixed + cancelLen
+	case CmdAmend:
+		le.PutUint64(q[0:], c.ID)
+		le.PutUint64(q[8:], uint64(c.Price))
+		le.PutUint64(q[16:], uint64(c.Qty))
+		le.PutUint64(q[24:], c.Seq)
+		return recFixed + amendLen
+	case CmdSession:
+		q[0] = byte(c.State)
+		return recFixed + sessionLen
+	}
+	return recFixed
+}
+
+func decodeCommand(p []byte) (uint64, Command, error) {
+	if len(p) < recFixed {
+		return 0, Command{}, ErrJournalCorrupt
+	}
+	le := binary.LittleEndian
+	seq := le.Uint64(p[0:])
+	c := Command{Kind: CommandKind(p[8]), Time: int64(le.Uint64(p[9:]))}
+	q := p[recFixed:]
+	want := 0
+	switch c.Kind {
+	case CmdPlace:
+		want = placeLen
+	case CmdCancel:
+		want = cancelLen
+	case CmdAmend:
+		want = amendLen
+	case CmdSession:
+		want = sessionLen
+	case CmdExpire, CmdEndOfDay, CmdAuction, CmdUncross:
+	default:
+		return 0, Command{}, ErrJournalCorrupt
+	}
+	if len(q) != want {
+		return 0, Command{}, ErrJournalCorrupt
+	}
+	switch c.Kind {
+	case CmdPlace:
+		c.Order = OrderSpec{
+			ID: le.Uint64(q[0:]), Seq: le.Uint64(q[8:]), Account: le.Uint64(q[16:]),
+			Side: Side(q[24]), Type: OrderType(q[25]),
+			Price: int64(le.Uint64(q[26:])), Qty: int64(le.Uint64(q[34:])),
+			StopPrice: int64(le.Uint64(q[42:])), Display: int64(le.Uint64(q[50:])),
+			TIF: TimeInForce(q[58]), ExpireAt: int64(le.Uint64(q[59:])),
+			Protect: int64(le.Uint64(q[67:])),
+		}
+	case CmdCancel:
+		c.ID = le.Uint64(q[0:])
+	case CmdAmend:
+		c.ID = le.Uint64(q[0:])
+		c.Price = int64(le.Uint64(q[8:]))
+		c.Qty = int64(le.Uint64(q[16:]))
+		c.Seq = le.Uint64(q[24:])
+	case CmdSession:
+		c.State = SessionState(q[0])
+	}
+	return seq, c, nil
+}
+
+// JournalReader iterates records, verifying the file header, then each
+// record's length, CRC and sequence.
+type JournalReader struct {
+	r       io.Reader
+	last    uint64
+	started bool // file header checked
+	buf     [maxRecordSz]byte
+}
+
+func NewJournalReader(r io.Reader) *JournalReader { return &JournalReader{r: r} }
+
+// Next returns the next record. io.EOF marks a clean end; ErrJournalTorn a
+// partial record at the tail (crash mid-append, never applied). A journal
+// without a header (written before it had one) or of another version fails
+// with ErrJournalFormat before any record is read.
+func (jr *JournalReader) Next() (uint64, Command, error) {
+	if !jr.started {
+		if err := jr.readHeader(); err != nil {
+			return 0, Command{}, err
+		}
+		jr.started = true
+	}
+	hdr := jr.buf[:recHeader]
+	if n, err := io.ReadFull(jr.r, hdr); err != nil {
+		if err == io.EOF {
+			return 0, Command{}, io.EOF
+		}
+		if n > 0 || err == io.ErrUnexpectedEOF {
+			return 0, Command{}, ErrJournalTorn
+		}
+		return 0, Command{}, err
+	}
+	size := binary.LittleEndian.Uint32(hdr[0:])
+	sum := binary.LittleEndian.Uint32(hdr[4:])
+	if size < recFixed || size > maxRecordSz-recHeader {
+		return 0, Command{}, ErrJournalCorrupt
+	}
+	body := jr.buf[recHeader : recHeader+int(size)]
+	if _, err := io.ReadFull(jr.r, body); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, Command{}, ErrJournalTorn
+		}
+		return 0, Command{}, err
+	}
+	if crc32.Checksum(body, crcTable) != sum {
+		return 0, Command{}, ErrJournalCorrupt
+	}
+	seq, c, err := decodeCommand(body)
+	if err != nil {
+		return 0, Command{}, err
+	}
+	if seq != jr.last+1 && jr.last != 0 {
+		return 0, Command{}, ErrJournalCorrupt
+	}
+	jr.last = seq
+	return seq, c, nil
+}
+
+func (jr *JournalReader) readHeader() error {
+	hdr := jr.buf[:journalHdrLen]
+	if n, err := io.ReadFull(jr.r, hdr); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		if n > 0 || err == io.ErrUnexpectedEOF {
+			return ErrJournalTorn
+		}
+		return err
+	}
+	if [4]byte(hdr[:4]) != journalMagic || binary.LittleEndian.Uint16(hdr[4:]) != journalVersion {
+		return ErrJournalFormat
+	}
+	return nil
+}
+
+// ---------------- Journaled book & replay ---------------- //
+
+// JournaledBook writes every command to the journal before applying it.
+// The book runs on a ManualClock set to each command's journaled time, so
+// replay reproduces time-dependent behaviour (GTD checks, expiry) exactly.
+type JournaledBook struct {
+	Book *OrderBook
+	j    *Journal
+	pool *OrderPool
+	rq   *retireRing
+	wall Clock
+	clk  *ManualClock
+}
+
+func NewJournaledBook(book *OrderBook, j *Journal, pool *OrderPool, rq *retireRing) *JournaledBook {
+	jb := &JournaledBook{Book: book, j: j, pool: pool, rq: rq, wall: book.clock}
+	jb.clk = NewManualClock(jb.wall.Now())
+	book.SetClock(jb.clk)
+	return jb
+}
+
+// Do journals and applies c. Resource rejects (pool, retire ring) depend on
+// reclaim timing rather than on the command stream, so they are decided
+// before journaling and never reach the journal. Nothing after that point
+// depends on ring space (expiry always completes), so replay, which
+// reclaims after every record, does exactly what the original run did.
+func (jb *JournaledBook) Do(c Command) (CommandResult, error) {
+	if !jb.Book.retireReady(jb.rq) {
+		return CommandResult{Reject: RejectRetireRingFull}, nil
+	}
+	if c.Kind == CmdPlace && jb.pool.Available() == 0 {
+		return CommandResult{Reject: RejectPoolExhausted}, nil
+	}
+	c.Time = jb.wall.Now()
+	if _, err := jb.j.Append(c); err != nil {
+		return CommandResult{}, err
+	}
+	jb.clk.Set(c.Time)
+	return jb.Book.Apply(c, jb.pool, jb.rq), nil
+}
+
+// Checkpoint writes the book stamped with the last journaled sequence.
+func (jb *JournaledBook) Checkpoint(w io.Writer) error {
+	return WriteCheckpoint(w, jb.Book, jb.j.Seq())
+}
+
+// Replay applies every record in r to 'book' and returns the last sequence
+// applied. Retired orders are reclaimed after each command, so 'pool' must
+// be at least as large as the original. A torn tail ends replay with
+// ErrJournalTorn after all complete records have been applied. A record
+// rejected for lack of pool or retire ring space was accepted when it was
+// journaled (Do never journals those), so the replayed book has diverged:
+// replay stops with ErrReplayDiverged and returns the last record applied.
+// The book is left on a ManualClock at the last record's time.
+func Replay(r io.Reader, book *OrderBook, pool *OrderPool, rq *retireRing) (uint64, error) {
+	return ReplayAfter(r, 0, book, pool, rq)
+}
+
+// ReplayAfter is Replay skipping records up to and including 'after', used
+// to roll a restored checkpoint forward (see RestoreCheckpoint).
+func ReplayAfter(r io.Reader, after uint64, book *OrderBook, pool *OrderPool, rq *retireRing) (uint64, error) {
+	clk := NewManualClock(0)
+	book.SetClock(clk)
+	jr := NewJournalReader(r)
+	var last uint64
+	for {
+		seq, c, err := jr.Next()
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return last, err
+		}
+		if seq <= after {
+			last = seq
+			continue
+		}
+		clk.Set(c.Time)
+		if r := book.Apply(c, pool, rq).Reject; r == RejectPoolExhausted || r == RejectRetireRingFull {
+			return last, ErrReplayDiverged
+		}
+		advanceEpochAndReclaim(rq, pool)
+		last = seq
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dumpBook renders every resting and pending order with its full state so
+// two books can be compared byte for byte.
+func dumpBook(b *OrderBook) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "seq=%d exec=%d last=%d\n", b.LastSeq.Load(), b.execSeq, b.lastTrade)
+	dumpLevel := func(tag string) func(*PriceLevel) bool {
+		return func(lvl *PriceLevel) bool {
+			fmt.Fprintf(&buf, "%s %d total=%d hidden=%d\n", tag, lvl.Price, lvl.TotalQty, lvl.HiddenQty)
+			for o := lvl.head; o != nil; o = o.next {
+				fmt.Fprintf(&buf, "  id=%d acct=%d side=%d type=%d px=%d qty=%d filled=%d stop=%d disp=%d hid=%d tif=%d exp=%d seq=%d\n",
+					o.ID, o.Account, o.Side, o.Type, o.Price, o.Qty, o.Filled, o.StopPrice,
+					o.display, o.hidden, o.TIF, o.ExpireAt, o.SeqID)
+			}
+			return true
+		}
+	}
+	b.Bids.ForEachDescending(dumpLevel("BID"))
+	b.Asks.ForEachAscending(dumpLevel("ASK"))
+	b.buyStops.ForEachAscending(dumpLevel("BSTOP"))
+	b.sellStops.ForEachDescending(dumpLevel("SSTOP"))
+	return buf.Bytes()
+}
+
+// scriptedCommands exercises every command kind and most order features.
+func scriptedCommands() []Command {
+	place := func(s OrderSpec) Command { return Command{Kind: CmdPlace, Order: s} }
+	return []Command{
+		place(OrderSpec{ID: 1, Seq: 1, Side: Ask, Type: Limit, Price: 101, Qty: 10}),
+		place(OrderSpec{ID: 2, Seq: 2, Side: Ask, Type: Limit, Price: 102, Qty: 30, Display: 5}),
+		place(OrderSpec{ID: 3, Seq: 3, Side: Bid, Type: Limit, Price: 99, Qty: 10, TIF: GTD, ExpireAt: 1_500}),
+		place(OrderSpec{ID: 4, Seq: 4, Side: Bid, Type: Limit, Price: 98, Qty: 10, TIF: DAY}),
+		place(OrderSpec{ID: 5, Seq: 5, Side: Bid, Type: Stop, StopPrice: 102, Qty: 7}),
+		place(OrderSpec{ID: 6, Seq: 6, Side: Bid, Type: Limit, Price: 101, Qty: 4}),
+		{Kind: CmdAmend, ID: 1, Price: 101, Qty: 3, Seq: 7},
+		place(OrderSpec{ID: 7, Seq: 8, Side: Bid, Type: IOC, Price: 102, Qty: 6}),
+		{Kind: CmdCancel, ID: 4},
+		{Kind: CmdExpire},
+		place(OrderSpec{ID: 8, Seq: 9, Side: Ask, Type: PostOnlySlide, Price: 95, Qty: 2}),
+		{Kind: CmdAmend, ID: 8, Price: 104, Qty: 5, Seq: 10},
+		place(OrderSpec{ID: 9, Seq: 11, Side: Bid, Type: Limit, Price: 97, Qty: 3, TIF: DAY}),
+		{Kind: CmdEndOfDay},
+		{Kind: CmdCancel, ID: 999},
+		{Kind: CmdAuction},
+		place(OrderSpec{ID: 10, Seq: 12, Side: Bid, Type: Limit, Price: 105, Qty: 4}),
+		{Kind: CmdUncross},
+		{Kind: CmdSession, State: Halted},
+		place(OrderSpec{ID: 11, Seq: 13, Side: Bid, Type: Limit, Price: 101, Qty: 2}), // rejected
+		{Kind: CmdSession, State: Continuous},
+		place(OrderSpec{ID: 12, Seq: 14, Side: Bid, Type: MarketToLimit, Qty: 3, Protect: 3}),
+	}
+}
+
+func runJournaled(t *testing.T, w *bytes.Buffer, policy FsyncPolicy) (*OrderBook, []Execution) {
+	t.Helper()
+	book, pool, rq := newTestEnv()
+	wall := NewManualClock(1_000)
+	book.SetClock(wall)
+	rec := &execRecorder{}
+	book.SetExecutionSink(rec)
+	jb := NewJournaledBook(book, NewJournal(w, policy, 4, 0), pool, rq)
+	for _, c := range scriptedCommands() {
+		if _, err := jb.Do(c); err != nil {
+			t.Fatal(err)
+		}
+		wall.Advance(100)
+	}
+	return book, rec.execs
+}
+
+func TestJournalReplayReproducesBook(t *testing.T) {
+	var w bytes.Buffer
+	orig, origExecs := runJournaled(t, &w, FsyncNone)
+
+	replayed, pool, rq := newTestEnv()
+	rec := &execRecorder{}
+	replayed.SetExecutionSink(rec)
+	last, err := Replay(bytes.NewReader(w.Bytes()), replayed, pool, rq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != uint64(len(scriptedCommands())) {
+		t.Errorf("expected last seq %d, got %d", len(scriptedCommands()), last)
+	}
+
+	a, b := dumpBook(orig), dumpBook(replayed)
+	if !bytes.Equal(a, b) {
+		t.Fatalf("replayed book differs:\n--- original\n%s--- replayed\n%s", a, b)
+	}
+	if len(origExecs) == 0 || len(origExecs) != len(rec.execs) {
+		t.Fatalf("expected identical execution streams, got %d vs %d", len(origExecs), len(rec.execs))
+	}
+	for i := range origExecs {
+		if origExecs[i] != rec.execs[i] {
+			t.Errorf("execution %d differs: %+v vs %+v", i, origExecs[i], rec.execs[i])
+		}
+	}
+}
+
+func TestJournalReplayDetectsDivergence(t *testing.T) {
+	var w bytes.Buffer
+	runJournaled(t, &w, FsyncNone)
+
+	// Too small a pool: the first place that cannot get an order was
+	// accepted originally, so replay must not carry on silently.
+	replayed := NewOrderBook()
+	last, err := Replay(bytes.NewReader(w.Bytes()), replayed, NewOrderPool(2), newRetireRing(64))
+	if err != ErrReplayDiverged {
+		t.Fatalf("expected ErrReplayDiverged, got %v", err)
+	}
+	if last == 0 || last >= uint64(len(scriptedCommands())) {
+		t.Errorf("expected replay to stop midway, stopped after %d", last)
+	}
+}
+
+// The original run never reclaims and its ring is nearly full when the day
+// ends; replay reclaims after every record. Both must expire the same orders.
+func TestJournalReplayExpiryIgnoresRingSpace(t *testing.T) {
+	var w bytes.Buffer
+	book, pool, rq := NewOrderBook(), NewOrderPool(64), newRetireRing(4)
+	book.SetClock(NewManualClock(1_000))
+	jb := NewJournaledBook(book, NewJournal(&w, FsyncNone, 0, 0), pool, rq)
+	for i := uint64(1); i <= 6; i++ {
+		_, _ = jb.Do(Command{Kind: CmdPlace, Order: OrderSpec{ID: i, Seq: i, Side: Bid, Type: Limit, Price: int64(90 + i), Qty: 5, TIF: DAY}})
+	}
+	_, _ = jb.Do(Command{Kind: CmdCancel, ID: 1})
+	_, _ = jb.Do(Command{Kind: CmdCancel, ID: 2})
+	res, err := jb.Do(Command{Kind: CmdEndOfDay})
+	if err != nil || res.Count != 4 {
+		t.Fatalf("end of day expired %d (%v), want all 4 live DAY orders", res.Count, err)
+	}
+
+	replayed := NewOrderBook()
+	if _, err := Replay(bytes.NewReader(w.Bytes()), replayed, NewOrderPool(64), newRetireRing(4)); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := dumpBook(book), dumpBook(replayed); !bytes.Equal(a, b) {
+		t.Fatalf("replayed book differs:\n--- original\n%s--- replayed\n%s", a, b)
+	}
+}
+
+func TestJournalRoundTrip(t *testing.T) {
+	var w bytes.Buffer
+	w.Write(journalMagic[:])
+	w.Write([]byte{journalVersion, 0})
+	j := NewJournal(&w, FsyncNone, 0, 41)
+	cmds := scriptedCommands()
+	for i := range cmds {
+		cmds[i].Time = int64(i) * 7
+		if seq, err := j.Append(cmds[i]); err != nil || seq != uint64(42+i) {
+			t.Fatalf("append %d: seq=%d err=%v", i, seq, err)
+		}
+	}
+	jr := NewJournalReader(bytes.NewReader(w.Bytes()))
+	for i := range cmds {
+		seq, c, err := jr.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if seq != uint64(42+i) || c != cmds[i] {
+			t.Errorf("record %d: got seq=%d %+v, want %+v", i, seq, c, cmds[i])
+		}
+	}
+	if _, _, err := jr.Next(); err == nil {
+		t.Error("expected EOF after last record")
+	}
+}
+
+func TestJournalDetectsCorruptionAndTornTail(t *testing.T) {
+	var w bytes.Buffer
+	_, _ = runJournaled(t, &w, FsyncNone)
+	data := w.Bytes()
+
+	// Flip a payload byte in the second record
+	bad := append([]byte(nil), data...)
+	first := journalHdrLen + recHeader + int(binary.LittleEndian.Uint32(bad[journalHdrLen:]))
+	bad[first+recHeader+20] ^= 0xff
+	book, pool, rq := newTestEnv()
+	if last, err := Replay(bytes.NewReader(bad), book, pool, rq); err != ErrJournalCorrupt || last != 1 {
+		t.Errorf("expected corruption after record 1, got last=%d err=%v", last, err)
+	}
+
+	// Cut the final record in half
+	torn := data[:len(data)-5]
+	book, pool, rq = newTestEnv()
+	last, err := Replay(bytes.NewReader(torn), book, pool, rq)
+	if err != ErrJournalTorn || last != uint64(len(scriptedCommands())-1) {
+		t.Errorf("expected torn tail after %d records, got last=%d err=%v", len(scriptedCommands())-1, last, err)
+	}
+}
+
+// A journal from before the header, or of another version, is refused up
+// front rather than read as corrupt records.
+func TestJournalRejectsUnknownFormat(t *testing.T) {
+	var w bytes.Buffer
+	_, _ = runJournaled(t, &w, FsyncNone)
+	data := w.Bytes()
+
+	other := append([]byte(nil), data...)
+	other[4]++
+	for name, in := range map[string][]byte{"headerless": data[journalHdrLen:], "other version": other} {
+		book, pool, rq := newTestEnv()
+		if last, err := Replay(bytes.NewReader(in), book, pool, rq); err != ErrJournalFormat || last != 0 {
+			t.Errorf("%s: got last=%d err=%v, want ErrJournalFormat", name, last, err)
+		}
+		if book.Bids.Size() != 0 || book.Asks.Size() != 0 {
+			t.Errorf("%s: book touched", name)
+		}
+	}
+
+	book, pool, rq := newTestEnv()
+	if last, err := Replay(bytes.NewReader(nil), book, pool, rq); err != nil || last != 0 {
+		t.Errorf("empty journal: got last=%d err=%v", last, err)
+	}
+}
+
+type syncCounter struct {
+	bytes.Buffer
+	syncs int
+}
+
+func (s *syncCounter) Sync() error { s.syncs++; return nil }
+
+func TestJournalFsyncPolicy(t *testing.T) {
+	for _, c := range []struct {
+		policy FsyncPolicy
+		batch  int
+		want   int
+	}{
+		{FsyncEvery, 0, 10},
+		{FsyncBatch, 4, 2},
+		{FsyncNone, 0, 0},
+	} {
+		w := &syncCounter{}
+		j := NewJournal(w, c.policy, c.batch, 0)
+		for i := 0; i < 10; i++ {
+			_, _ = j.Append(Command{Kind: CmdCancel, ID: uint64(i)})
+		}
+		if w.syncs != c.want {
+			t.Errorf("policy %d: expected %d syncs, got %d", c.policy, c.want, w.syncs)
+		}
+	}
+}
+
+func TestJournalFileReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.wal")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, pool, rq := newTestEnv()
+	jb := NewJournaledBook(book, NewJournal(f, FsyncEvery, 0, 0), pool, rq)
+	for _, c := range scriptedCommands()[:6] {
+		if _, err := jb.Do(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	replayed, pool2, rq2 := newTestEnv()
+	if _, err := Replay(f, replayed, pool2, rq2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dumpBook(book), dumpBook(replayed)) {
+		t.Error("replay from file differs from original")
+	}
+}
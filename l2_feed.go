@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+// ---------------- Incremental L2 (price level) feed ---------------- //
+//
+// Every change to a visible PriceLevel emits one delta carrying the level's
+// state after the change, tagged with a gap-free feed sequence. Subscribers
+// take a SnapshotDepth (stamped with FeedSeq = N) and apply deltas > N.
+
+type L2Action uint8
+
+const (
+	L2New    L2Action = iota + 1 // level created
+	L2Change                     // displayed quantity or order count changed
+	L2Delete                     // level removed
+)
+
+type L2Delta struct {
+	Seq    uint64
+	Side   Side
+	Action L2Action
+	Price  int64
+	Qty    int64 // displayed quantity after the change (0 on delete)
+	Orders int
+}
+
+// L2Sink receives level deltas on the matcher thread.
+type L2Sink interface {
+	OnL2Delta(d L2Delta)
+}
+
+// L2SinkFunc adapts a plain function to L2Sink.
+type L2SinkFunc func(d L2Delta)
+
+func (f L2SinkFunc) OnL2Delta(d L2Delta) { f(d) }
+
+// SetL2Sink installs the sink for level deltas (nil disables).
+func (b *OrderBook) SetL2Sink(s L2Sink) { b.l2 = s }
+
+// emitL2 reports the current state of the visible level at 'price' and
+// publishes it to snapshot readers.
+func (b *OrderBook) emitL2(side Side, price int64, lvl *PriceLevel, action L2Action) {
+	seq := b.l2Seq.Add(1)
+	b.publish(side, price, lvl, action, seq)
+	if b.l2 == nil {
+		return
+	}
+	d := L2Delta{Seq: seq, Side: side, Action: action, Price: price}
+	if action != L2Delete {
+		d.Qty, d.Orders = lvl.TotalQty, lvl.Count
+	}
+	b.l2.OnL2Delta(d)
+}
+
+// ---------------- Reference subscriber ---------------- //
+
+var ErrL2Gap = errors.New("l2: sequence gap")
+
+// L2Book rebuilds aggregated depth from a snapshot plus deltas.
+type L2Book struct {
+	seq  uint64
+	bids map[int64]DepthLevel
+	asks map[int64]DepthLevel
+}
+
+// NewL2Book starts from a full-depth snapshot.
+func NewL2Book(snap *Depth) *L2Book {
+	lb := &L2Book{
+		seq:  snap.FeedSeq,
+		bids: make(map[int64]DepthLevel, len(snap.Bids)),
+		asks: make(map[int64]DepthLevel, len(snap.Asks)),
+	}
+	for _, l := range snap.Bids {
+		lb.bids[l.Price] = l
+	}
+	for _, l := range snap.Asks {
+		lb.asks[l.Price] = l
+	}
+	return lb
+}
+
+// Seq returns the last feed sequence applied.
+func (lb *L2Book) Seq() uint64 { return lb.seq }
+
+// Apply folds one delta in. Deltas already covered by the snapshot are
+// ignored; a missing sequence returns ErrL2Gap (resubscribe from a snapshot).
+func (lb *L2Book) Apply(d L2Delta) error {
+	if d.Seq <= lb.seq {
+		return nil
+	}
+	if d.Seq != lb.seq+1 {
+		return ErrL2Gap
+	}
+	lb.seq = d.Seq
+	side := lb.bids
+	if d.Side == Ask {
+		side = lb.asks
+	}
+	if d.Action == L2Delete {
+		delete(side, d.Price)
+	} else {
+		side[d.Price] = DepthLevel{Price: d.Price, Qty: d.Qty, Orders: d.Orders}
+	}
+	return nil
+}
+
+// Levels returns one side best price first.
+func (lb *L2Book) Levels(side Side) []DepthLevel {
+	m := lb.bids
+	if side == Ask {
+		m = lb.asks
+	}
+	out := make([]DepthLevel, 0, len(m))
+	for _, l := range m {
+		out = append(out, l)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if side == Bid {
+			return out[i].Price > out[j].Price
+		}
+		return out[i].Price < out[j].Price
+	})
+	return out
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// churn drives a book through n random commands (limits, IOCs, icebergs,
+// amends, cancels) around a mid price of 100.
+func churn(book *OrderBook, pool *OrderPool, rq *retireRing, rng *rand.Rand, firstID uint64, n int) {
+	for i := 0; i < n; i++ {
+		id := firstID + uint64(i)
+		side := Side(rng.Intn(2))
+		price := int64(95 + rng.Intn(11))
+		qty := int64(1 + rng.Intn(20))
+		switch k := rng.Intn(10); {
+		case k < 5:
+			_, _ = book.placeOrder(side, Limit, price, id, qty, id, pool, rq)
+		case k == 5:
+			_, _ = book.placeOrder(side, IOC, price, id, qty, id, pool, rq)
+		case k == 6:
+			_, _ = book.submit(OrderSpec{ID: id, Seq: id, Side: side, Type: Limit, Price: price, Qty: qty + 10, Display: 3}, pool, rq)
+		case k == 7:
+			_, _ = book.Amend(firstID+uint64(rng.Intn(i+1)), price, qty, id, rq)
+		default:
+			_ = book.CancelByID(firstID+uint64(rng.Intn(i+1)), rq)
+		}
+		advanceEpochAndReclaim(rq, pool)
+	}
+}
+
+func TestL2DeltaShapes(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	var got []L2Delta
+	book.SetL2Sink(L2SinkFunc(func(d L2Delta) { got = append(got, d) }))
+
+	_, _ = book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 100, 2, 3, 2, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 3, 6, 3, pool, rq)
+
+	want := []L2Delta{
+		{Seq: 1, Side: Bid, Action: L2New, Price: 100, Qty: 5, Orders: 1},
+		{Seq: 2, Side: Bid, Action: L2Change, Price: 100, Qty: 8, Orders: 2},
+		{Seq: 3, Side: Bid, Action: L2Change, Price: 100, Qty: 3, Orders: 1}, // order 1 filled
+		{Seq: 4, Side: Bid, Action: L2Change, Price: 100, Qty: 2, Orders: 1}, // order 2 partially
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d deltas, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("delta %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	_ = book.CancelByID(2, rq)
+	if last := got[len(got)-1]; last.Action != L2Delete || last.Price != 100 || last.Seq != 5 {
+		t.Errorf("expected level delete, got %+v", last)
+	}
+}
+
+func TestL2SnapshotPlusDeltasMatchesBook(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	var deltas []L2Delta
+	book.SetL2Sink(L2SinkFunc(func(d L2Delta) { deltas = append(deltas, d) }))
+	rng := rand.New(rand.NewSource(7))
+
+	churn(book, pool, rq, rng, 1, 500)
+	var snap Depth
+	book.SnapshotDepth(&Reader{}, 1<<20, &snap)
+	churn(book, pool, rq, rng, 1_000, 2_000)
+
+	lb := NewL2Book(&snap)
+	for _, d := range deltas {
+		if err := lb.Apply(d); err != nil {
+			t.Fatalf("delta %d: %v", d.Seq, err)
+		}
+	}
+
+	var live Depth
+	book.SnapshotDepth(&Reader{}, 1<<20, &live)
+	if len(live.Bids) == 0 || len(live.Asks) == 0 || len(snap.Bids) == 0 {
+		t.Fatal("churn left a one-sided book; test would prove nothing")
+	}
+	if lb.Seq() != live.FeedSeq {
+		t.Fatalf("subscriber at seq %d, book at %d", lb.Seq(), live.FeedSeq)
+	}
+	for _, side := range []Side{Bid, Ask} {
+		want := live.Bids
+		if side == Ask {
+			want = live.Asks
+		}
+		got := lb.Levels(side)
+		if len(got) != len(want) {
+			t.Fatalf("side %d: %d levels, want %d", side, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("side %d level %d = %+v, want %+v", side, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestL2BookDetectsGap(t *testing.T) {
+	lb := NewL2Book(&Depth{FeedSeq: 10})
+	if err := lb.Apply(L2Delta{Seq: 9, Side: Bid, Action: L2New, Price: 1, Qty: 1}); err != nil {
+		t.Errorf("stale delta should be ignored, got %v", err)
+	}
+	if err := lb.Apply(L2Delta{Seq: 12, Side: Bid, Action: L2New, Price: 1, Qty: 1}); err != ErrL2Gap {
+		t.Errorf("expected gap, got %v", err)
+	}
+}
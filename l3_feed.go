@@ -0,0 +1,147 @@
+package main
+
+import "errors"
+
+// ---------------- Order-by-order (L3) feed ---------------- //
+//
+// Every change to a visible resting order emits one event, ITCH style:
+//   - L3Add: order joins the back of its level (also an iceberg refill)
+//   - L3Execute: resting order traded Qty
+//   - L3Reduce: resting order shrank by Qty in place (amend, STP decrement)
+//   - L3Delete: order left the book with quantity still showing
+// An order whose displayed quantity reaches zero is gone; no delete follows.
+// Pending stops and iceberg reserve are never shown.
+
+type L3Action uint8
+
+const (
+	L3Add L3Action = iota + 1
+	L3Reduce
+	L3Execute
+	L3Delete
+)
+
+type L3Event struct {
+	Seq    uint64
+	Action L3Action
+	ID     uint64
+	Side   Side
+	Price  int64
+	Qty    int64 // shown on add, removed on reduce/execute, 0 on delete
+}
+
+// L3Sink receives order events on the matcher thread.
+type L3Sink interface {
+	OnL3Event(e L3Event)
+}
+
+// L3SinkFunc adapts a plain function to L3Sink.
+type L3SinkFunc func(e L3Event)
+
+func (f L3SinkFunc) OnL3Event(e L3Event) { f(e) }
+
+// SetL3Sink installs the sink for order events (nil disables).
+func (b *OrderBook) SetL3Sink(s L3Sink) { b.l3 = s }
+
+func (b *OrderBook) emitL3(lvl *PriceLevel, action L3Action, o *Order, qty int64) {
+	b.stage(lvl, action, o)
+	b.l3Seq++
+	if b.l3 == nil {
+		return
+	}
+	b.l3.OnL3Event(L3Event{
+		Seq: b.l3Seq, Action: action, ID: o.ID, Side: o.Side, Price: o.Price, Qty: qty,
+	})
+}
+
+// reduce shrinks o in place via lvl.Reduce, reporting the displayed cut.
+func (b *OrderBook) reduce(lvl *PriceLevel, o *Order, qty int64) {
+	shown := o.Qty
+	lvl.Reduce(o, qty)
+	if isStop(o.Type) {
+		return
+	}
+	if cut := shown - o.Qty; cut > 0 {
+		b.emitL3(lvl, L3Reduce, o, cut)
+	} else {
+		b.stage(lvl, L3Reduce, o) // reserve only: nothing shown changed
+	}
+}
+
+// ---------------- Reference book builder ---------------- //
+
+var (
+	ErrL3Gap   = errors.New("l3: sequence gap")
+	ErrL3State = errors.New("l3: event does not match book state")
+	ErrL3Pool  = errors.New("l3: order pool exhausted")
+)
+
+// L3Builder rebuilds a full OrderBook from an L3 stream that starts at
+// sequence 1. Orders removed from the book are retired into rq; the caller
+// reclaims them as usual.
+type L3Builder struct {
+	book *OrderBook
+	pool *OrderPool
+	rq   *retireRing
+	seq  uint64
+}
+
+func NewL3Builder(book *OrderBook, pool *OrderPool, rq *retireRing) *L3Builder {
+	return &L3Builder{book: book, pool: pool, rq: rq}
+}
+
+// Book returns the book being rebuilt.
+func (lb *L3Builder) Book() *OrderBook { return lb.book }
+
+// Seq returns the last event sequence applied.
+func (lb *L3Builder) Seq() uint64 { return lb.seq }
+
+// Apply folds one event into the book.
+func (lb *L3Builder) Apply(e L3Event) error {
+	if e.Seq != lb.seq+1 {
+		return ErrL3Gap
+	}
+	b := lb.book
+	if e.Action == L3Add {
+		o := lb.pool.Get()
+		if o == nil {
+			return ErrL3Pool
+		}
+		*o = Order{ID: e.ID, Side: e.Side, Type: Limit, Price: e.Price, Qty: e.Qty, SeqID: e.Seq, Status: Active}
+		if !b.index.insert(o) {
+			lb.pool.Put(o)
+			return ErrL3State
+		}
+		b.enqueue(o)
+		lb.seq = e.Seq
+		return nil
+	}
+
+	o, r := b.Lookup(e.ID)
+	if r != RejectNone {
+		return ErrL3State
+	}
+	switch e.Action {
+	case L3Execute, L3Reduce:
+		if e.Action == L3Execute {
+			o.Filled += e.Qty
+			b.lastTrade = e.Price
+		}
+		left := o.Qty - e.Qty
+		if left < 0 {
+			left = 0
+		}
+		lvl := b.levelOf(o)
+		lvl.Reduce(o, left)
+		b.emitL3(lvl, e.Action, o, e.Qty)
+		if o.Qty == 0 {
+			b.remove(o.Price, o, lb.rq, o.Side)
+		} else {
+			b.emitL2(o.Side, lvl.Price, lvl, L2Change)
+		}
+	case L3Delete:
+		b.remove(o.Price, o, lb.rq, o.Side)
+	}
+	lb.seq = e.Seq
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+type l3Row struct {
+	id    uint64
+	price int64
+	qty   int64
+}
+
+// l3Rows lists the visible book in FIFO order per level.
+func l3Rows(b *OrderBook) []l3Row {
+	var rows []l3Row
+	b.SnapshotActiveIter(&Reader{}, func(p int64, o *Order) {
+		if !isStop(o.Type) {
+			rows = append(rows, l3Row{o.ID, p, o.Qty})
+		}
+	})
+	return rows
+}
+
+func TestL3EventShapes(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	var got []L3Event
+	book.SetL3Sink(L3SinkFunc(func(e L3Event) { got = append(got, e) }))
+
+	_, _ = book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 100, 2, 3, 2, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 3, 6, 3, pool, rq)
+	_, _ = book.Amend(2, 100, 1, 4, rq)
+	_ = book.CancelByID(2, rq)
+
+	want := []L3Event{
+		{Seq: 1, Action: L3Add, ID: 1, Side: Bid, Price: 100, Qty: 5},
+		{Seq: 2, Action: L3Add, ID: 2, Side: Bid, Price: 100, Qty: 3},
+		{Seq: 3, Action: L3Execute, ID: 1, Side: Bid, Price: 100, Qty: 5}, // gone, no delete
+		{Seq: 4, Action: L3Execute, ID: 2, Side: Bid, Price: 100, Qty: 1},
+		{Seq: 5, Action: L3Reduce, ID: 2, Side: Bid, Price: 100, Qty: 1},
+		{Seq: 6, Action: L3Delete, ID: 2, Side: Bid, Price: 100},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestL3IcebergRefillIsNewAdd(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	var got []L3Event
+	book.SetL3Sink(L3SinkFunc(func(e L3Event) { got = append(got, e) }))
+
+	_, _ = placeIceberg(book, Ask, 100, 1, 10, 4, pool, rq)
+	_, _ = book.placeOrder(Bid, IOC, 100, 2, 4, 2, pool, rq)
+
+	if len(got) != 3 {
+		t.Fatalf("expected add, execute, refill; got %+v", got)
+	}
+	if got[0].Qty != 4 || got[1].Action != L3Execute || got[1].Qty != 4 {
+		t.Errorf("unexpected slice events %+v", got[:2])
+	}
+	if got[2].Action != L3Add || got[2].ID != 1 || got[2].Qty != 4 {
+		t.Errorf("expected refill add of 4, got %+v", got[2])
+	}
+}
+
+func TestL3BuilderRebuildsBook(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	lpool, lrq := NewOrderPool(1<<14), newRetireRing(1<<12)
+	lb := NewL3Builder(NewOrderBook(), lpool, lrq)
+	book.SetL3Sink(L3SinkFunc(func(e L3Event) {
+		if err := lb.Apply(e); err != nil {
+			t.Fatalf("event %+v: %v", e, err)
+		}
+		advanceEpochAndReclaim(lrq, lpool)
+	}))
+	book.SetSelfTradePrevention(STPDecrement, nil)
+
+	rng := rand.New(rand.NewSource(11))
+	churn(book, pool, rq, rng, 1, 3_000)
+	for i := 0; i < 200; i++ { // self-trades exercise STP decrement
+		id := uint64(10_000 + i)
+		_, _ = book.submit(OrderSpec{ID: id, Seq: id, Account: 7, Side: Side(i % 2), Type: Limit, Price: int64(98 + rng.Intn(5)), Qty: 5}, pool, rq)
+		advanceEpochAndReclaim(rq, pool)
+	}
+
+	want, got := l3Rows(book), l3Rows(lb.Book())
+	if len(want) == 0 {
+		t.Fatal("churn left an empty book; test would prove nothing")
+	}
+	if lb.Seq() != book.l3Seq {
+		t.Fatalf("builder at seq %d, book at %d", lb.Seq(), book.l3Seq)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("rebuilt %d orders, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestL3BuilderRejectsGapAndUnknown(t *testing.T) {
+	lb := NewL3Builder(NewOrderBook(), NewOrderPool(4), newRetireRing(4))
+	if err := lb.Apply(L3Event{Seq: 2, Action: L3Add, ID: 1, Price: 1, Qty: 1}); err != ErrL3Gap {
+		t.Errorf("expected gap, got %v", err)
+	}
+	if err := lb.Apply(L3Event{Seq: 1, Action: L3Delete, ID: 9}); err != ErrL3State {
+		t.Errorf("expected state error, got %v", err)
+	}
+}
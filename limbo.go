@@ -0,0 +1,69 @@
+package main
+
+// limbo holds retired orders on the reclaiming side until no reader can
+// see them. Orders are grouped by the epoch they were retired in, oldest
+// group first, so one sweep frees every group older than the oldest reader
+// and stops at the first group still visible. It is fed only from the
+// retire ring's consumer side; nothing is ever pushed back into the ring.
+type limbo struct {
+	groups []limboGroup // oldest epoch first, live from groups[head]
+	head   int
+	spare  [][]*Order // emptied group slices for reuse
+	n      int
+}
+
+type limboGroup struct {
+	epoch  uint64
+	orders []*Order
+}
+
+// add files o under its retire epoch. Epochs arrive non-decreasing (the
+// ring preserves retirement order), so o joins the newest group or opens one.
+func (l *limbo) add(o *Order) {
+	if k := len(l.groups); k > l.head && l.groups[k-1].epoch == o.retireEpoch {
+		g := &l.groups[k-1]
+		g.orders = append(g.orders, o)
+	} else {
+		var s []*Order
+		if k := len(l.spare); k > 0 {
+			s, l.spare = l.spare[k-1], l.spare[:k-1]
+		}
+		l.groups = append(l.groups, limboGroup{epoch: o.retireEpoch, orders: append(s, o)})
+	}
+	l.n++
+}
+
+// fill drains rq into limbo.
+func (l *limbo) fill(rq *retireRing) {
+	for o := rq.Dequeue(); o != nil; o = rq.Dequeue() {
+		l.add(o)
+	}
+}
+
+// pop removes and returns the oldest group if it was retired before 'min'
+// (^uint64(0): no reader, everything goes), else nil. The slice stays valid
+// until the next add.
+func (l *limbo) pop(min uint64) []*Order {
+	if l.head == len(l.groups) {
+		return nil
+	}
+	g := l.groups[l.head]
+	if min != ^uint64(0) && g.epoch >= min {
+		return nil
+	}
+	l.groups[l.head] = limboGroup{}
+	l.head++
+	if l.head == len(l.groups) {
+		l.groups, l.head = l.groups[:0], 0
+	} else if l.head > len(l.groups)/2 { // compact, amortised over the pops since
+		k := copy(l.groups, l.groups[l.head:])
+		clear(l.groups[k:])
+		l.groups, l.head = l.groups[:k], 0
+	}
+	l.spare = append(l.spare, g.orders[:0])
+	l.n -= len(g.orders)
+	return g.orders
+}
+
+// Len returns the number of orders waiting.
+func (l *limbo) Len() int { return l.n }
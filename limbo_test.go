@@ -0,0 +1,184 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimboGroupsByEpoch(t *testing.T) {
+	var l limbo
+	for i, e := range []uint64{3, 3, 4, 6, 6, 6} {
+		l.add(&Order{ID: uint64(i), retireEpoch: e})
+	}
+	if len(l.groups) != 3 || l.Len() != 6 {
+		t.Fatalf("expected 3 groups of 6 orders, got %d of %d", len(l.groups), l.Len())
+	}
+	if g := l.pop(3); g != nil {
+		t.Errorf("group of epoch 3 freed with a reader in epoch 3")
+	}
+	var freed int
+	for g := l.pop(6); g != nil; g = l.pop(6) {
+		freed += len(g)
+	}
+	if freed != 3 || l.Len() != 3 {
+		t.Errorf("expected epochs 3 and 4 freed in one sweep, freed %d, %d left", freed, l.Len())
+	}
+	if g := l.pop(^uint64(0)); len(g) != 3 || l.Len() != 0 {
+		t.Errorf("expected the last group with no reader, got %d", len(g))
+	}
+}
+
+// Nothing goes back into the ring: a blocked order stays in limbo and the
+// ring keeps its single producer.
+func TestReclaimLeavesRingToProducer(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	r := RegisterReader()
+	defer UnregisterReader(r)
+	r.EnterRead()
+	for id := uint64(1); id <= 10; id++ {
+		_, _ = book.placeOrder(Bid, Limit, 100, id, 5, id, pool, rq)
+		_ = book.CancelByID(id, rq)
+		advanceEpochAndReclaim(rq, pool)
+	}
+	if rq.Len() != 0 || rq.held.Len() != 10 {
+		t.Fatalf("expected all 10 in limbo and none in the ring, got %d and %d", rq.held.Len(), rq.Len())
+	}
+	if len(rq.held.groups)-rq.held.head != 10 {
+		t.Errorf("expected one group per epoch, got %d", len(rq.held.groups)-rq.held.head)
+	}
+	r.ExitRead()
+	advanceEpochAndReclaim(rq, pool)
+	if rq.held.Len() != 0 || pool.Available() != 1<<12 {
+		t.Errorf("expected one sweep to free everything, %d held", rq.held.Len())
+	}
+}
+
+// retiredSince tracks orders retired while a reader is pinned, by the ID
+// each had; an order handed out again by the pool gets a new identity.
+type retiredSince map[*Order]uint64
+
+func (rs retiredSince) reused() bool {
+	for o, id := range rs {
+		if o.ID != id || o.Status != Inactive {
+			return true
+		}
+	}
+	return false
+}
+
+// A reader stays pinned across thousands of epochs of churn with inline
+// reclamation; nothing it could see may be recycled, and every order
+// retired before it entered must still be.
+func TestLongReaderInlineReclaim(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	rng := rand.New(rand.NewSource(21))
+	churn(book, pool, rq, rng, 1, 500)
+
+	r := RegisterReader()
+	defer UnregisterReader(r)
+	r.EnterRead()
+	pinned := r.epoch.Load()
+
+	seen := retiredSince{}
+	for i := uint64(0); i < 3_000; i++ {
+		id := 10_000 + i
+		o, res := book.placeOrder(Side(i%2), Limit, int64(90+i%7), id, 5, id, pool, rq)
+		if res == RejectPoolExhausted {
+			break
+		}
+		if i%2 == 1 && book.CancelByID(id, rq) == RejectNone {
+			seen[o] = id
+		}
+		advanceEpochAndReclaim(rq, pool)
+		for _, g := range rq.held.groups[rq.held.head:] {
+			if g.epoch < pinned {
+				t.Fatalf("step %d: group of epoch %d kept behind reader at %d", i, g.epoch, pinned)
+			}
+		}
+	}
+	if len(seen) == 0 {
+		t.Fatal("no orders retired under the reader")
+	}
+	if seen.reused() {
+		t.Fatal("order recycled while the reader could see it")
+	}
+	r.ExitRead()
+	advanceEpochAndReclaim(rq, pool)
+	if rq.held.Len() != 0 {
+		t.Errorf("%d orders left in limbo after the reader left", rq.held.Len())
+	}
+}
+
+// The same with a background reclaimer and a reader goroutine that holds
+// each read for a while. Run with -race.
+func TestLongReaderBackgroundReclaim(t *testing.T) {
+	book, pool, rq := NewOrderBook(), NewOrderPool(1<<12), newRetireRing(64)
+	rc := NewReclaimer(ReclaimerConfig{Interval: 50 * time.Microsecond})
+	rc.Add(pool, rq)
+	rc.Start()
+	defer rc.Stop()
+
+	r := RegisterReader()
+	defer UnregisterReader(r)
+	for id := uint64(1); id <= 50; id++ {
+		_, _ = book.placeOrder(Ask, Limit, int64(100+id%5), id, 5, id, pool, rq)
+	}
+	entered, leave := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan string, 1)
+	wg.Add(1)
+	go func() { // one long read, re-walking the version it started on
+		defer wg.Done()
+		defer r.ExitRead()
+		r.EnterRead()
+		v := book.view.Load()
+		close(entered)
+		for {
+			select {
+			case <-leave:
+				return
+			default:
+			}
+			if msg := checkView(v); msg != "" || v.asks.len() != 5 {
+				errs <- "pinned version changed under the reader"
+				return
+			}
+			time.Sleep(20 * time.Microsecond)
+		}
+	}()
+	<-entered
+	pinned := r.epoch.Load()
+
+	seen := retiredSince{}
+	for id := uint64(100); id < 2_100; id++ {
+		o := (*Order)(nil)
+		for {
+			var res RejectReason
+			o, res = book.placeOrder(Bid, Limit, int64(90+id%7), id, 5, id, pool, rq)
+			if res != RejectRetireRingFull {
+				break
+			}
+			time.Sleep(10 * time.Microsecond)
+		}
+		if o != nil && cancelRetrying(book, id, rq) == RejectNone {
+			seen[o] = id
+		}
+	}
+	waitFor(t, "reclaimer to drain the ring", func() bool { return rq.Len() == 0 && rc.Passes() > 0 })
+	if seen.reused() {
+		t.Fatal("order recycled while the reader was pinned")
+	}
+	if pool.Available() > 1<<12-50-len(seen) {
+		t.Fatalf("%d free with %d orders pinned (reader at epoch %d)", pool.Available(), len(seen), pinned)
+	}
+
+	close(leave)
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Error(msg)
+	}
+	waitFor(t, "pinned orders recycled", func() bool { return pool.Available() == 1<<12-50 })
+}
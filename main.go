@@ -3,78 +3,96 @@ package main
 import (
 	"fmt"
 	"runtime"
-)
-
-var (
-	// Bigger pools/rings for 200k+ TPS
-	orderPool = NewOrderPool(1 << 20)  // 1M orders
-	retireQ   = newRetireRing(1 << 18) // 256k retired
-	reader    Reader
-	book      = NewOrderBook()
+	"time"
 )
 
 func main() {
-	// Pin matcher to dedicated OS thread (avoid scheduler migration)
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-
 	globalEpoch.Store(100)
 
+	// Bigger pools/rings for 200k+ TPS, shared by all instruments
+	engine := NewEngine(
+		NewOrderPool(1<<20),  // 1M orders
+		newRetireRing(1<<18), // 256k retired
+	)
+
+	// Prices are integer cents (2 decimals), quantities in round lots
+	for _, sym := range []string{"AAPL", "MSFT"} {
+		book := engine.AddInstrument(Instrument{
+			Symbol: sym, Currency: "USD", PriceScale: 2, TickSize: 1, LotSize: 100,
+		}, nil, nil)
+		inst := book.Instrument()
+		// Print every fill as it happens (on the matcher thread)
+		book.SetExecutionSink(ExecutionSinkFunc(func(e Execution) {
+			fmt.Printf("  [%s exec #%d] O%d x O%d %d @ %s %s\n",
+				sym, e.Seq, e.AggressorID, e.RestingID, e.Qty, inst.FormatPrice(e.Price), inst.Currency)
+		}))
+	}
+
+	// Recycle retired orders in the background
+	reclaimer := engine.StartReclaimer(ReclaimerConfig{Interval: time.Millisecond})
+	defer reclaimer.Stop()
+
+	// From here on only the matcher (pinned to its own OS thread) touches
+	// the engine; this goroutine is a gateway
+	matcher := NewMatcher(engine, MatcherConfig{})
+	matcher.Start()
+	defer matcher.Stop()
+	place := func(sym string, spec OrderSpec) {
+		_, _ = matcher.Do(sym, Command{Kind: CmdPlace, Order: spec})
+	}
+	reader := RegisterReader()
+	defer UnregisterReader(reader)
+
 	// --- Demo: Add initial orders --- //
 	fmt.Println("Placing initial bid/ask orders...")
 
-	// Place a bid @100
-	o1 := book.placeOrder(Bid, Limit, 100, 1, 10_000, 1, orderPool, retireQ)
-	// Place another bid @100
-	_ = book.placeOrder(Bid, Limit, 100, 2, 20_000, 2, orderPool, retireQ)
-	// Place an ask @101
-	_ = book.placeOrder(Ask, Limit, 101, 3, 15_000, 3, orderPool, retireQ)
+	// Place a bid @100.00
+	place("AAPL", OrderSpec{ID: 1, Seq: 1, Side: Bid, Type: Limit, Price: 100_00, Qty: 10_000})
+	// Place another bid @100.00
+	place("AAPL", OrderSpec{ID: 2, Seq: 2, Side: Bid, Type: Limit, Price: 100_00, Qty: 20_000})
+	// Place an ask @101.00
+	place("AAPL", OrderSpec{ID: 3, Seq: 3, Side: Ask, Type: Limit, Price: 101_00, Qty: 15_000})
+	// Same order IDs are independent per instrument
+	place("MSFT", OrderSpec{ID: 1, Seq: 1, Side: Ask, Type: Limit, Price: 250_00, Qty: 1_000})
 
 	fmt.Println("Init snapshot:")
-	book.SnapshotActiveIter(&reader, func(p int64, o *Order) {
-		side := "BID"
-		if o.Side == Ask {
-			side = "ASK"
-		}
-		fmt.Printf("  %s %d: O%d qty=%d\n", side, p, o.ID, o.Qty)
-	})
+	printSnapshot(engine, reader, "  ")
 
 	// --- Cancel demo --- //
-	book.cancelOrder(o1.Price, o1, retireQ, o1.Side)
+	_, _ = matcher.Do("AAPL", Command{Kind: CmdCancel, ID: 1})
 
 	// Snapshot in parallel
 	done := make(chan struct{})
+	snap := RegisterReader()
 	go func() {
 		runtime.LockOSThread() // pin snapshotter too
 		defer runtime.UnlockOSThread()
-		book.SnapshotActiveIter(&reader, func(p int64, o *Order) {
-			side := "BID"
-			if o.Side == Ask {
-				side = "ASK"
-			}
-			fmt.Printf("[snap] %s %d: O%d\n", side, p, o.ID)
-		})
+		defer UnregisterReader(snap)
+		printSnapshot(engine, snap, "[snap] ")
 		close(done)
 	}()
 
 	// Place IOC order (buy that should cancel leftover)
-	_ = book.placeOrder(Bid, IOC, 101, 4, 5_000, 4, orderPool, retireQ)
-
-	// First reclaim (reader active → canceled not yet recycled)
-	advanceEpochAndReclaim(retireQ, orderPool, &reader)
+	place("AAPL", OrderSpec{ID: 4, Seq: 4, Side: Bid, Type: IOC, Price: 101_00, Qty: 5_000})
 
 	<-done
 
-	// Second reclaim (reader done → canceled recycled)
-	advanceEpochAndReclaim(retireQ, orderPool, &reader)
-
 	// --- Final snapshot --- //
 	fmt.Println("Final snapshot:")
-	book.SnapshotActiveIter(&reader, func(p int64, o *Order) {
-		side := "BID"
-		if o.Side == Ask {
-			side = "ASK"
-		}
-		fmt.Printf("  %s %d: O%d qty=%d\n", side, p, o.ID, o.Qty)
-	})
+	printSnapshot(engine, reader, "  ")
+}
+
+// printSnapshot is safe off the matcher thread: the listing is fixed before
+// the matcher starts and snapshots read the published views.
+func printSnapshot(engine *Engine, r *Reader, prefix string) {
+	for _, sym := range engine.Symbols() {
+		inst := engine.Book(sym).Instrument()
+		_ = engine.Snapshot(sym, r, func(p int64, o *Order) {
+			side := "BID"
+			if o.Side == Ask {
+				side = "ASK"
+			}
+			fmt.Printf("%s%s %s %s: O%d qty=%d\n", prefix, sym, side, inst.FormatPrice(p), o.ID, o.Qty)
+		})
+	}
 }
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ---------------- Matcher runtime ---------------- //
+//
+// A Matcher owns an Engine and is the only goroutine that ever touches it.
+// Gateway goroutines push commands into a bounded multi-producer ring; the
+// matcher, pinned to its OS thread, drains it in arrival order and answers
+// each command on the caller's reply channel. Without a background
+// reclaimer it reclaims after every batch; when the ring runs dry it parks
+// until the next push.
+//
+// Replies carry a copy of the order, never a live pointer: the matcher
+// keeps changing resting orders after it answers.
+
+var (
+	ErrMatcherBusy    = errors.New("matcher: command ring full")
+	ErrMatcherStopped = errors.New("matcher: stopped")
+)
+
+// Response answers one command.
+type Response struct {
+	Seq     uint64 // arrival order at the matcher, from 1
+	Reject  RejectReason
+	Amend   AmendResult
+	Count   int           // CmdExpire, CmdEndOfDay
+	Order   Order         // CmdPlace: the accepted order as the command left it
+	Auction AuctionResult // CmdUncross, CmdSession
+	Err     error         // CmdSession, CmdAuction, CmdUncross
+}
+
+type MatcherConfig struct {
+	RingSize uint64 // command slots, power of two (default 4096)
+	Spin     int    // empty polls before parking (default 64)
+}
+
+type Matcher struct {
+	engine  *Engine
+	cfg     MatcherConfig
+	ring    *cmdRing
+	seq     uint64
+	parked  atomic.Bool
+	wake    chan struct{}
+	stopped atomic.Bool
+	pushing atomic.Int64 // Submits between their stop check and push
+	done    chan struct{}
+	replies sync.Pool // chan Response, for Do
+}
+
+func NewMatcher(e *Engine, cfg MatcherConfig) *Matcher {
+	if cfg.RingSize == 0 {
+		cfg.RingSize = 1 << 12
+	}
+	if cfg.Spin <= 0 {
+		cfg.Spin = 64
+	}
+	m := &Matcher{engine: e, cfg: cfg, ring: newCmdRing(cfg.RingSize), wake: make(chan struct{}, 1)}
+	m.replies.New = func() any { return make(chan Response, 1) }
+	return m
+}
+
+// Start runs the matcher goroutine. The engine must not be used directly
+// from here on.
+func (m *Matcher) Start() {
+	m.done = make(chan struct{})
+	go m.run()
+}
+
+// Stop refuses new commands, lets the matcher finish those already queued
+// and waits for it to exit.
+func (m *Matcher) Stop() {
+	m.stopped.Store(true)
+	m.signal()
+	<-m.done
+}
+
+// Submit queues c for 'symbol' without waiting. The response goes to
+// 'reply' (nil: not wanted), which must have room for it: the matcher
+// never blocks on a slow caller.
+func (m *Matcher) Submit(symbol string, c Command, reply chan<- Response) error {
+	m.pushing.Add(1)
+	if m.stopped.Load() {
+		m.pushing.Add(-1)
+		return ErrMatcherStopped
+	}
+	ok := m.ring.push(symbol, c, reply)
+	m.pushing.Add(-1)
+	if !ok {
+		return ErrMatcherBusy
+	}
+	if m.parked.Load() {
+		m.signal()
+	}
+	return nil
+}
+
+// Do queues c and waits for its response, yielding while the ring is full.
+func (m *Matcher) Do(symbol string, c Command) (Response, error) {
+	reply := m.replies.Get().(chan Response)
+	for {
+		err := m.Submit(symbol, c, reply)
+		if err == nil {
+			break
+		}
+		if err != ErrMatcherBusy {
+			m.replies.Put(reply)
+			return Response{}, err
+		}
+		runtime.Gosched()
+	}
+	res := <-reply
+	m.replies.Put(reply)
+	return res, nil
+}
+
+func (m *Matcher) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Matcher) run() {
+	runtime.LockOSThread() // one matcher thread, never migrated
+	defer runtime.UnlockOSThread()
+	defer close(m.done)
+
+	idle := 0
+	for {
+		if m.drain() > 0 {
+			if m.engine.reclaimer == nil {
+				m.engine.Reclaim()
+			}
+			idle = 0
+			continue
+		}
+		if idle++; idle < m.cfg.Spin {
+			runtime.Gosched()
+			continue
+		}
+		if m.stopped.Load() {
+			// Submits that passed their stop check have pushed once
+			// 'pushing' is back to zero; later ones see the flag.
+			for m.pushing.Load() > 0 {
+				runtime.Gosched()
+			}
+			m.drain()
+			return
+		}
+		m.engine.Reclaim() // catch up (or nudge the reclaimer) before sleeping
+		m.parked.Store(true)
+		if m.ring.empty() && !m.stopped.Load() {
+			<-m.wake
+		}
+		m.parked.Store(false)
+		idle = 0
+	}
+}
+
+// drain applies every queued command and returns how many it applied.
+func (m *Matcher) drain() int {
+	n := 0
+	for s := m.ring.peek(); s != nil; s = m.ring.peek() {
+		sym, c, reply := s.sym, s.cmd, s.reply
+		m.ring.release(s)
+		m.seq++
+		r := m.engine.Apply(sym, c)
+		if reply != nil {
+			res := Response{Seq: m.seq, Reject: r.Reject, Amend: r.Amend, Count: r.Count, Auction: r.Auction, Err: r.Err}
+			if r.Order != nil {
+				res.Order = *r.Order
+				res.Order.next, res.Order.prev = nil, nil
+			}
+			reply <- res
+		}
+		n++
+	}
+	return n
+}
+
+// ---------------- Command ring ---------------- //
+
+// cmdRing is a bounded MPSC queue. Each slot carries a sequence number:
+// equal to the position when free for that lap, position+1 once filled.
+// Producers claim a position with a CAS on tail, fill the slot and publish
+// it by bumping its sequence; the consumer frees it for the next lap.
+type cmdRing struct {
+	tail  atomic.Uint64 // next position to claim (producers)
+	_pad1 [56]byte
+	head  uint64 // next position to read (consumer)
+	_pad2 [56]byte
+
+	slots []cmdSlot
+	mask  uint64
+}
+
+type cmdSlot struct {
+	seq   atomic.Uint64
+	sym   string
+	cmd   Command
+	reply chan<- Response
+}
+
+func newCmdRing(pow2 uint64) *cmdRing {
+	q := &cmdRing{slots: make([]cmdSlot, pow2), mask: pow2 - 1}
+	for i := range q.slots {
+		q.slots[i].seq.Store(uint64(i))
+	}
+	return q
+}
+
+func (q *cmdRing) push(sym string, c Command, reply chan<- Response) bool {
+	for {
+		pos := q.tail.Load()
+		s := &q.slots[pos&q.mask]
+		switch seq := s.seq.Load(); {
+		case seq == pos:
+			if q.tail.CompareAndSwap(pos, pos+1) {
+				s.sym, s.cmd, s.reply = sym, c, reply
+				s.seq.Store(pos + 1)
+				return true
+			}
+		case seq < pos:
+			return false // a lap behind: full
+		}
+		// another producer took pos; retry
+	}
+}
+
+// peek returns the next filled slot, or nil.
+func (q *cmdRing) peek() *cmdSlot {
+	s := &q.slots[q.head&q.mask]
+	if s.seq.Load() != q.head+1 {
+		return nil
+	}
+	return s
+}
+
+// release frees the slot peek returned for the next lap.
+func (q *cmdRing) release(s *cmdSlot) {
+	s.sym, s.cmd, s.reply = "", Command{}, nil
+	s.seq.Store(q.head + uint64(len(q.slots)))
+	q.head++
+}
+
+// empty reports whether nothing is queued (consumer side).
+func (q *cmdRing) empty() bool { return q.peek() == nil }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCmdRingOrderAndCapacity(t *testing.T) {
+	q := newCmdRing(4)
+	for i := uint64(1); i <= 4; i++ {
+		if !q.push("X", Command{Kind: CmdCancel, ID: i}, nil) {
+			t.Fatalf("push %d refused", i)
+		}
+	}
+	if q.push("X", Command{Kind: CmdCancel, ID: 5}, nil) {
+		t.Fatal("full ring accepted a command")
+	}
+	for lap := 0; lap < 3; lap++ { // wrap around a few times
+		s := q.peek()
+		want := uint64(lap + 1)
+		if s == nil || s.cmd.ID != want {
+			t.Fatalf("lap %d: expected command %d first", lap, want)
+		}
+		q.release(s)
+		if !q.push("X", Command{Kind: CmdCancel, ID: want + 4}, nil) {
+			t.Fatalf("lap %d: freed slot not reusable", lap)
+		}
+	}
+}
+
+func TestMatcherDoRoundTrip(t *testing.T) {
+	e := NewEngine(NewOrderPool(64), newRetireRing(64))
+	e.AddBook("AAPL")
+	m := NewMatcher(e, MatcherConfig{})
+	m.Start()
+	defer m.Stop()
+
+	res, err := m.Do("AAPL", Command{Kind: CmdPlace, Order: OrderSpec{ID: 1, Seq: 1, Side: Bid, Type: Limit, Price: 100, Qty: 10}})
+	if err != nil || res.Reject != RejectNone || res.Seq != 1 || res.Order.ID != 1 || res.Order.Qty != 10 {
+		t.Fatalf("place: %+v, %v", res, err)
+	}
+	res, _ = m.Do("AAPL", Command{Kind: CmdPlace, Order: OrderSpec{ID: 2, Seq: 2, Side: Ask, Type: IOC, Price: 100, Qty: 4}})
+	if res.Seq != 2 || res.Order.Filled != 4 || res.Order.Qty != 0 {
+		t.Errorf("expected IOC filled 4, got %+v", res.Order)
+	}
+	res, _ = m.Do("AAPL", Command{Kind: CmdAmend, ID: 1, Price: 100, Qty: 2, Seq: 3})
+	if res.Reject != RejectNone || res.Amend != AmendedInPlace {
+		t.Errorf("amend: %+v", res)
+	}
+	res, _ = m.Do("MSFT", Command{Kind: CmdCancel, ID: 1})
+	if res.Reject != RejectUnknownSymbol {
+		t.Errorf("expected unknown symbol, got %v", res.Reject)
+	}
+	res, _ = m.Do("AAPL", Command{Kind: CmdCancel, ID: 1})
+	if res.Reject != RejectNone {
+		t.Errorf("cancel: %v", res.Reject)
+	}
+}
+
+func TestMatcherBusyAndStopped(t *testing.T) {
+	e := NewEngine(NewOrderPool(8), newRetireRing(8))
+	m := NewMatcher(e, MatcherConfig{RingSize: 2})
+	for i := 0; i < 2; i++ {
+		if err := m.Submit("AAPL", Command{Kind: CmdExpire}, nil); err != nil {
+			t.Fatalf("submit %d: %v", i, err)
+		}
+	}
+	if err := m.Submit("AAPL", Command{Kind: CmdExpire}, nil); err != ErrMatcherBusy {
+		t.Errorf("expected busy, got %v", err)
+	}
+
+	m.Start() // drains what was queued before it started
+	if res, err := m.Do("AAPL", Command{Kind: CmdExpire}); err != nil || res.Seq != 3 {
+		t.Errorf("expected third command, got seq %d (%v)", res.Seq, err)
+	}
+	m.Stop()
+	if err := m.Submit("AAPL", Command{Kind: CmdExpire}, nil); err != ErrMatcherStopped {
+		t.Errorf("expected stopped, got %v", err)
+	}
+	if _, err := m.Do("AAPL", Command{Kind: CmdExpire}); err != ErrMatcherStopped {
+		t.Errorf("expected stopped from Do, got %v", err)
+	}
+}
+
+// Gateways on many goroutines; only the matcher touches the engine.
+// Run with -race.
+func TestMatcherConcurrentGateways(t *testing.T) {
+	e := NewEngine(NewOrderPool(1<<12), newRetireRing(1<<10))
+	e.AddBook("AAPL")
+	e.AddBook("MSFT")
+	m := NewMatcher(e, MatcherConfig{RingSize: 64})
+	m.Start()
+
+	const gateways, perGateway = 8, 500
+	var wg sync.WaitGroup
+	seqs := make([][]uint64, gateways)
+	errs := make(chan string, gateways)
+	for g := 0; g < gateways; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			sym := []string{"AAPL", "MSFT"}[g%2]
+			for i := 0; i < perGateway; i++ {
+				id := uint64(g*perGateway + i + 1)
+				side, price := Bid, int64(95+i%5)
+				if g%4 >= 2 {
+					side, price = Ask, int64(98+i%5)
+				}
+				res, err := m.Do(sym, Command{Kind: CmdPlace, Order: OrderSpec{ID: id, Seq: id, Side: side, Type: Limit, Price: price, Qty: 5}})
+				if err != nil || (res.Reject != RejectNone && res.Reject != RejectRetireRingFull) {
+					errs <- "place rejected: " + res.Reject.String()
+					return
+				}
+				seqs[g] = append(seqs[g], res.Seq)
+				if res.Reject != RejectNone || res.Order.Qty == 0 {
+					continue
+				}
+				for { // may have been filled meanwhile (already done)
+					res, _ = m.Do(sym, Command{Kind: CmdCancel, ID: id})
+					seqs[g] = append(seqs[g], res.Seq)
+					if res.Reject != RejectRetireRingFull {
+						break
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	m.Stop()
+	close(errs)
+	for msg := range errs {
+		t.Fatal(msg)
+	}
+
+	seen := map[uint64]bool{}
+	for _, s := range seqs {
+		for i, q := range s {
+			if seen[q] || (i > 0 && q <= s[i-1]) {
+				t.Fatalf("response sequence %d duplicated or out of order", q)
+			}
+			seen[q] = true
+		}
+	}
+	if uint64(len(seen)) != m.seq {
+		t.Errorf("%d responses for %d commands", len(seen), m.seq)
+	}
+	for _, sym := range e.Symbols() {
+		n := 0
+		e.Book(sym).SnapshotActiveIter(&Reader{}, func(int64, *Order) { n++ })
+		if n != 0 {
+			t.Errorf("%s: %d orders left resting", sym, n)
+		}
+	}
+}
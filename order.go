@@ -17,19 +17,38 @@ const (
 const (
 	Limit OrderType = iota
 	Market
-	IOC      // Immediate-Or-Cancel
-	FOK      // Fill-Or-Kill
-	PostOnly // Must not cross book
+	IOC           // Immediate-Or-Cancel
+	FOK           // Fill-Or-Kill
+	PostOnly      // Must not cross book
+	PostOnlySlide // Post-only, repriced one tick behind the opposite best if it would cross
+	Stop          // Becomes Market once the last trade reaches StopPrice
+	StopLimit     // Becomes Limit once the last trade reaches StopPrice
+	MarketToLimit // Market, remainder rests as Limit at its last execution price
+)
+
+// TimeInForce controls how long an unfilled order may rest.
+type TimeInForce uint8
+
+const (
+	GTC TimeInForce = iota // Good-Till-Cancel (default)
+	DAY                    // Expires at end of day
+	GTD                    // Expires at ExpireAt
 )
 
 // Order represents a single order in the book
 type Order struct {
 	ID          uint64
+	Account     uint64 // owner for self-trade prevention (0 = anonymous)
 	Side        Side
 	Type        OrderType
 	Price       int64
 	Qty         int64
 	Filled      int64
+	StopPrice   int64 // trigger price for Stop/StopLimit
+	display     int64 // iceberg refresh size (0 = fully displayed)
+	hidden      int64 // iceberg reserve not shown in the level
+	TIF         TimeInForce
+	ExpireAt    int64 // GTD expiry, clock nanoseconds
 	SeqID       uint64
 	Status      OrderStatus
 	next, prev  *Order   // FIFO queue inside a price level
@@ -37,10 +56,29 @@ type Order struct {
 	_           [32]byte // padding for cache line separation
 }
 
-// OrderPool: fixed-capacity stack pool (no GC churn in steady state)
+// OrderSpec describes an incoming order; zero optional fields mean "unused".
+type OrderSpec struct {
+	ID        uint64
+	Account   uint64
+	Seq       uint64
+	Side      Side
+	Type      OrderType
+	Price     int64 // limit price (ignored for Market, MarketToLimit and Stop)
+	Qty       int64
+	StopPrice int64 // Stop/StopLimit only
+	Display   int64 // iceberg display quantity (0 = not an iceberg)
+	TIF       TimeInForce
+	ExpireAt  int64 // GTD only, clock nanoseconds
+	Protect   int64 // Market/MarketToLimit: max ticks through the opposite best at arrival
+}
+
+// OrderPool: fixed-capacity stack pool (no GC churn in steady state).
+// Get and Put belong to the matcher thread. A background Reclaimer hands
+// orders back through the 'returns' ring instead, drained by Get.
 type OrderPool struct {
-	store []*Order
-	top   int
+	store   []*Order
+	top     int
+	returns *retireRing // reclaimer→matcher, nil until shared
 }
 
 func NewOrderPool(cap int) *OrderPool {
@@ -52,6 +90,9 @@ func NewOrderPool(cap int) *OrderPool {
 }
 
 func (p *OrderPool) Get() *Order {
+	if p.top == 0 && p.returns != nil {
+		p.refill()
+	}
 	if p.top == 0 {
 		return nil // exhausted
 	}
@@ -70,3 +111,111 @@ func (p *OrderPool) Put(o *Order) {
 	p.store[p.top] = o
 	p.top++
 }
+
+// RejectReason explains why a command was not applied (RejectNone = accepted).
+type RejectReason uint8
+
+const (
+	RejectNone            RejectReason = iota
+	RejectUnknownOrder                 // no order with that ID
+	RejectAlreadyDone                  // order already filled or cancelled (too late)
+	RejectInvalidQty                   // quantity must be positive
+	RejectInvalidPrice                 // limit price must be positive
+	RejectDuplicateID                  // a live order already uses this ID
+	RejectPoolExhausted                // no free Order in the pool
+	RejectRetireRingFull               // reclaimer is behind; retry later
+	RejectWouldCross                   // post-only order would take liquidity
+	RejectInvalidDisplay               // iceberg display qty out of range or type never rests
+	RejectInvalidExpiry                // GTD expiry missing or already passed
+	RejectUnknownSymbol                // no book for that instrument
+	RejectOffTick                      // price is not a multiple of the tick size
+	RejectOffLot                       // quantity is not a multiple of the lot size
+	RejectQtyOutOfRange                // quantity below min or above max order size
+	RejectPriceOutOfRange              // price outside the instrument's static band
+	RejectAuctionCall                  // order type not accepted during an auction call
+	RejectSessionState                 // not accepted in the current session state
+	RejectProtection                   // protection negative or set on a priced order
+	RejectInvalidTIF                   // unknown time-in-force
+)
+
+func (r RejectReason) String() string {
+	switch r {
+	case RejectNone:
+		return "none"
+	case RejectUnknownOrder:
+		return "unknown order"
+	case RejectAlreadyDone:
+		return "already done"
+	case RejectInvalidQty:
+		return "invalid quantity"
+	case RejectInvalidPrice:
+		return "invalid price"
+	case RejectDuplicateID:
+		return "duplicate order ID"
+	case RejectPoolExhausted:
+		return "order pool exhausted"
+	case RejectRetireRingFull:
+		return "retire ring full"
+	case RejectWouldCross:
+		return "post-only would cross"
+	case RejectInvalidDisplay:
+		return "invalid display quantity"
+	case RejectInvalidExpiry:
+		return "invalid expiry"
+	case RejectUnknownSymbol:
+		return "unknown symbol"
+	case RejectOffTick:
+		return "price off tick"
+	case RejectOffLot:
+		return "quantity off lot"
+	case RejectQtyOutOfRange:
+		return "quantity out of range"
+	case RejectPriceOutOfRange:
+		return "price out of range"
+	case RejectAuctionCall:
+		return "not accepted during auction call"
+	case RejectSessionState:
+		return "not accepted in session state"
+	case RejectProtection:
+		return "invalid market protection"
+	case RejectInvalidTIF:
+		return "invalid time in force"
+	}
+	return "unknown reason"
+}
+
+// Available returns the number of free orders left in the pool.
+func (p *OrderPool) Available() int {
+	if p.returns != nil {
+		return p.top + int(p.returns.Len())
+	}
+	return p.top
+}
+
+// share lets another goroutine return orders with giveBack.
+func (p *OrderPool) share() {
+	if p.returns == nil {
+		n := uint64(1)
+		for n < uint64(len(p.store)) {
+			n <<= 1
+		}
+		p.returns = newRetireRing(n)
+	}
+}
+
+// giveBack returns o from the reclaimer goroutine. It never writes to o:
+// the matcher may still be reading it, and Get resets it anyway. The ring
+// holds the whole pool, so it never fills.
+func (p *OrderPool) giveBack(o *Order) { _ = p.returns.Enqueue(o) }
+
+// refill moves returned orders back onto the stack.
+func (p *OrderPool) refill() {
+	for p.top < len(p.store) {
+		o := p.returns.Dequeue()
+		if o == nil {
+			return
+		}
+		p.store[p.top] = o
+		p.top++
+	}
+}
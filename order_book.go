@@ -6,237 +6,529 @@ type OrderBook struct {
 	Bids    *RBTree
 	Asks    *RBTree
 	LastSeq atomic.Uint64
+
+	buyStops  *RBTree // pending buy stops keyed by stop price
+	sellStops *RBTree // pending sell stops keyed by stop price
+	lastTrade int64   // price of the most recent execution (0 = none yet)
+
+	execSeq uint64        // last execution sequence handed out
+	sink    ExecutionSink // trade reports (optional)
+	index   *orderIndex   // order ID → live order
+	parked  []*Order      // retired while the retire ring was full
+
+	inst Instrument // tick/lot/quantity rules and price rendering
+
+	clock    Clock      // time source for TIF expiry
+	expiries expiryHeap // pending GTD expiries
+
+	stp     STPMode       // self-trade prevention policy
+	stpSink SelfTradeSink // prevented-trade reports (optional)
+
+	l2    L2Sink        // level deltas (optional)
+	l2Seq atomic.Uint64 // last level delta sequence
+
+	l3    L3Sink // order-by-order events (optional)
+	l3Seq uint64 // last order event sequence
+
+	view  atomic.Pointer[bookView] // latest published snapshot view
+	arena viewArena                // recycled view memory (matcher only)
+
+	auction     auctionState // call phase (see auction.go)
+	session     SessionState // trading session (see session.go)
+	sessionSeq  uint64       // last session event sequence
+	sessionSink SessionSink  // session changes (optional)
+	bands       bandState    // execution price bands (see bands.go)
 }
 
 func NewOrderBook() *OrderBook {
-	return &OrderBook{
-		Bids: NewRBTree(),
-		Asks: NewRBTree(),
+	b := &OrderBook{
+		Bids:  NewRBTree(),
+		Asks:  NewRBTree(),
+		index: newOrderIndex(defaultIndexCap),
+
+		buyStops:  NewRBTree(),
+		sellStops: NewRBTree(),
+
+		inst:  Instrument{}.normalized(),
+		clock: wallClock{},
 	}
+	b.view.Store(&bookView{})
+	return b
 }
 
 // ---------------- Matching Engine ---------------- //
 
-// Place an order (runs matching first, then rests if needed)
+// Place an order (runs matching first, then rests if needed).
+// Returns a nil order and the reason if the order is rejected upfront.
 func (b *OrderBook) placeOrder(
 	side Side, otype OrderType, price int64,
 	id uint64, qty int64, seq uint64,
 	pool *OrderPool, rq *retireRing,
-) *Order {
+) (*Order, RejectReason) {
+	return b.submit(OrderSpec{
+		ID: id, Seq: seq, Side: side, Type: otype, Price: price, Qty: qty,
+	}, pool, rq)
+}
+
+// submit validates and accepts an order described by 's', then executes it
+// (or parks it in the trigger book) and releases any stops its trades hit.
+func (b *OrderBook) submit(s OrderSpec, pool *OrderPool, rq *retireRing) (*Order, RejectReason) {
+	if r := b.acceptsOrders(s.Type); r != RejectNone {
+		return nil, r
+	}
+	if r := b.validate(s.Type, s.Price, s.Qty); r != RejectNone {
+		return nil, r
+	}
+	if isStop(s.Type) {
+		if r := b.inst.checkPrice(s.StopPrice); r != RejectNone {
+			return nil, r
+		}
+	}
+	if s.Display != 0 {
+		if s.Display < 0 || s.Display > s.Qty || !canRest(s.Type) {
+			return nil, RejectInvalidDisplay
+		}
+		if s.Display%b.inst.LotSize != 0 {
+			return nil, RejectOffLot
+		}
+	}
+	if s.Protect < 0 || (s.Protect > 0 && !isMarket(s.Type)) {
+		return nil, RejectProtection
+	}
+	if s.TIF > GTD {
+		return nil, RejectInvalidTIF
+	}
+	if s.TIF == GTD && s.ExpireAt <= b.clock.Now() {
+		return nil, RejectInvalidExpiry
+	}
+	if isPostOnly(s.Type) {
+		p, r := b.postOnlyPrice(s.Type, s.Side, s.Price)
+		if r != RejectNone {
+			return nil, r
+		}
+		s.Price = p
+	}
+	if x := b.index.find(s.ID); x != nil && x.state == slotLive {
+		return nil, RejectDuplicateID
+	}
+	if !b.retireReady(rq) {
+		return nil, RejectRetireRingFull
+	}
+	b.ExpireOrders(rq) // expired orders must not trade
 	o := pool.Get()
 	if o == nil {
-		panic("order pool exhausted")
+		return nil, RejectPoolExhausted
+	}
+	if isMarket(s.Type) {
+		s.Price = b.protectionPrice(s.Side, s.Protect)
 	}
 	*o = Order{
-		ID: id, Side: side, Type: otype, Price: price,
-		Qty: qty, SeqID: seq, Status: Active,
+		ID: s.ID, Account: s.Account, Side: s.Side, Type: s.Type, Price: s.Price,
+		Qty: s.Qty, SeqID: s.Seq, StopPrice: s.StopPrice, Status: Active,
+		display: s.Display, TIF: s.TIF, ExpireAt: s.ExpireAt,
 	}
-	b.LastSeq.Store(seq)
+	b.LastSeq.Store(s.Seq)
+	b.index.insert(o)
 
-	// Market orders don’t use price
-	if o.Type == Market {
-		o.Price = 0
+	if isStop(o.Type) {
+		if o.Type == Stop {
+			o.Price = 0
+		}
+		b.enqueue(o) // may already be through: releaseStops fires it
+	} else if b.calling() {
+		b.enqueue(o) // accumulates until the uncross
+	} else {
+		b.execute(o, rq)
 	}
+	b.scheduleExpiry(o)
+	b.releaseStops(rq)
+	return o, RejectNone
+}
 
+// execute matches a live order and decides what to do with the leftover.
+func (b *OrderBook) execute(o *Order, rq *retireRing) {
 	// --- Special handling for FOK (dry-run) ---
 	if o.Type == FOK {
-		available := b.checkLiquidity(side, o.Price, o.Qty)
+		available := b.checkLiquidity(o, o.Price)
 		if available < o.Qty {
-			// Not enough liquidity → reject w/o partial fill
-			o.Status = Inactive
-			_ = rq.Enqueue(o)
-			return o
+			// Not enough liquidity → kill w/o partial fill
+			b.retire(o, rq)
+			return
 		}
 	}
 
-	// Match against opposite side
-	matched := b.match(o, rq)
-	o.Filled = matched
+	// Match against opposite side (post-only never crosses at this point)
+	filled, killed := int64(0), false
+	if !isPostOnly(o.Type) {
+		filled, killed = b.match(o, rq)
+	}
+
+	// Fully filled aggressors are done, and so are cancelled remainders
+	if o.Qty == 0 || killed {
+		b.retire(o, rq)
+		return
+	}
 
 	// Decide what to do with leftover
 	switch o.Type {
-	case Limit:
-		if o.Qty > 0 {
-			b.enqueue(o)
-		}
-	case PostOnly:
-		if matched > 0 {
-			// Rejected if it crosses
-			o.Status = Inactive
-			_ = rq.Enqueue(o)
-		} else if o.Qty > 0 {
-			b.enqueue(o)
-		}
-	case IOC:
-		if o.Qty > 0 {
-			o.Status = Inactive
-			_ = rq.Enqueue(o)
-		}
-	case FOK:
-		// At this point, full fill is guaranteed (by precheck)
-		if o.Qty > 0 {
-			o.Status = Inactive
-			_ = rq.Enqueue(o)
-		}
-	case Market:
-		if o.Qty > 0 {
-			o.Status = Inactive
-			_ = rq.Enqueue(o)
+	case Limit, PostOnly, PostOnlySlide:
+		b.enqueue(o)
+	case IOC, FOK, Market:
+		// FOK full fill is guaranteed by the precheck; the rest never rest
+		b.retire(o, rq)
+	case MarketToLimit:
+		if filled == 0 { // no price to rest at
+			b.retire(o, rq)
+			return
 		}
+		o.Type, o.Price = Limit, b.lastTrade // our own last fill
+		b.enqueue(o)
 	}
-	return o
 }
 
-// match executes trades against opposite side
-func (b *OrderBook) match(o *Order, rq *retireRing) int64 {
-	filled := int64(0)
+// validate checks order parameters before anything is allocated.
+func (b *OrderBook) validate(otype OrderType, price, qty int64) RejectReason {
+	if r := b.inst.checkQty(qty); r != RejectNone {
+		return r
+	}
+	if !isMarket(otype) && otype != Stop {
+		return b.inst.checkPrice(price)
+	}
+	return RejectNone
+}
 
-	if o.Side == Bid {
-		for o.Qty > 0 {
-			bestAsk := b.Asks.MinLevel()
-			if bestAsk == nil || (o.Type != Market && bestAsk.Price > o.Price) {
-				break
-			}
-			head := bestAsk.head
-			trade := min(o.Qty, head.Qty)
-			o.Qty -= trade
-			head.Qty -= trade
-			filled += trade
-
-			if head.Qty == 0 {
-				b.cancelOrder(bestAsk.Price, head, rq, Ask)
-			}
+// match executes trades against opposite side. 'killed' reports that a band
+// breach or self-trade prevention cancelled the remainder, left in o.Qty for
+// the caller to retire.
+func (b *OrderBook) match(o *Order, rq *retireRing) (filled int64, killed bool) {
+	lo, hi := b.bandRange() // anchored at arrival
+
+	for o.Qty > 0 {
+		lvl := b.bestOpposite(o.Side)
+		if lvl == nil || (o.Price != 0 && !crosses(o.Side, o.Price, lvl.Price)) { // 0: unprotected market
+			break
 		}
-	} else { // Ask
-		for o.Qty > 0 {
-			bestBid := b.Bids.MaxLevel()
-			if bestBid == nil || (o.Type != Market && bestBid.Price < o.Price) {
-				break
-			}
-			head := bestBid.head
-			trade := min(o.Qty, head.Qty)
-			o.Qty -= trade
-			head.Qty -= trade
-			filled += trade
-
-			if head.Qty == 0 {
-				b.cancelOrder(bestBid.Price, head, rq, Bid)
+		if lvl.Price < lo || lvl.Price > hi {
+			killed = b.breach(o, lvl.Price, lo, hi, filled, rq)
+			break
+		}
+		head := lvl.head
+		if b.stp != STPNone && o.Account != 0 && head.Account == o.Account {
+			if b.preventSelfTrade(o, head, lvl, rq) {
+				return filled, true
 			}
+			continue
 		}
+		trade := min(o.Qty, head.Qty)
+		o.Qty -= trade
+		head.Qty -= trade
+		o.Filled += trade
+		head.Filled += trade
+		lvl.TotalQty -= trade
+		filled += trade
+		b.lastTrade = lvl.Price
+		b.emitExecution(o, head, lvl.Price, trade)
+		b.settle(lvl, head, trade, rq)
+	}
+	return filled, killed
+}
+
+// settle publishes a fill of 'trade' already taken off resting order o,
+// then refills it (iceberg) or removes it once nothing is left showing.
+func (b *OrderBook) settle(lvl *PriceLevel, o *Order, trade int64, rq *retireRing) {
+	b.emitL3(lvl, L3Execute, o, trade)
+	switch {
+	case o.Qty > 0:
+		b.emitL2(o.Side, lvl.Price, lvl, L2Change)
+	case lvl.Replenish(o): // iceberg refill goes to the back as a new add
+		b.emitL3(lvl, L3Add, o, o.Qty)
+		b.emitL2(o.Side, lvl.Price, lvl, L2Change)
+	default:
+		b.remove(lvl.Price, o, rq, o.Side)
+	}
+}
+
+// bestOpposite returns the best level an order on 'side' can trade against.
+func (b *OrderBook) bestOpposite(side Side) *PriceLevel {
+	if side == Bid {
+		return b.Asks.MinLevel()
+	}
+	return b.Bids.MaxLevel()
+}
+
+// crosses reports whether an order on 'side' limited at 'limit' may trade at 'price'.
+func crosses(side Side, limit, price int64) bool {
+	if side == Bid {
+		return price <= limit
 	}
-	return filled
+	return price >= limit
 }
 
-// enqueue leftover order into book
+// enqueue leftover order into book (pending stops go to the trigger book)
 func (b *OrderBook) enqueue(o *Order) {
-	if o.Side == Bid {
-		lvl := b.Bids.UpsertLevel(o.Price)
-		lvl.Enqueue(o)
+	if isStop(o.Type) {
+		b.stopTree(o.Side).UpsertLevel(o.StopPrice).Enqueue(o)
+		return
+	}
+	// Icebergs show only the display slice; the rest goes to reserve
+	if o.display > 0 && o.Qty > o.display {
+		o.hidden += o.Qty - o.display
+		o.Qty = o.display
+	}
+	lvl := b.tree(o.Side).UpsertLevel(o.Price)
+	lvl.Enqueue(o)
+	b.emitL3(lvl, L3Add, o, o.Qty)
+	if lvl.Count == 1 {
+		b.emitL2(o.Side, lvl.Price, lvl, L2New)
 	} else {
-		lvl := b.Asks.UpsertLevel(o.Price)
-		lvl.Enqueue(o)
+		b.emitL2(o.Side, lvl.Price, lvl, L2Change)
 	}
 }
 
 // cancel order and recycle
-func (b *OrderBook) cancelOrder(price int64, o *Order, rq *retireRing, side Side) {
+func (b *OrderBook) cancelOrder(price int64, o *Order, rq *retireRing, side Side) RejectReason {
+	if o == nil {
+		return RejectUnknownOrder
+	}
+	if o.Status != Active {
+		return RejectAlreadyDone
+	}
+	if !b.retireReady(rq) {
+		return RejectRetireRingFull
+	}
+	b.remove(price, o, rq, side)
+	return RejectNone
+}
+
+// remove takes a live order out of the book and retires it.
+func (b *OrderBook) remove(price int64, o *Order, rq *retireRing, side Side) {
 	o.Status = Inactive
-	o.retireEpoch = globalEpoch.Load()
+	b.unlink(price, o, side)
+	b.retire(o, rq)
+}
 
-	var lvl *PriceLevel
-	if side == Bid {
-		lvl = b.Bids.FindLevel(price)
+// unlink removes o from its price level, dropping the level once empty.
+func (b *OrderBook) unlink(price int64, o *Order, side Side) {
+	t := b.tree(side)
+	if isStop(o.Type) {
+		t, price = b.stopTree(side), o.StopPrice
+	}
+	lvl := t.FindLevel(price)
+	if lvl == nil {
+		return
+	}
+	lvl.unlinkAlreadyInactive(o)
+	if lvl.head == nil {
+		_ = t.DeleteLevel(price)
+	}
+	if isStop(o.Type) {
+		return
+	}
+	if o.Qty > 0 { // fully executed orders already left the L3 feed
+		b.emitL3(lvl, L3Delete, o, 0)
+	}
+	if lvl.head == nil {
+		b.emitL2(side, price, lvl, L2Delete)
 	} else {
-		lvl = b.Asks.FindLevel(price)
+		b.emitL2(side, price, lvl, L2Change)
 	}
+}
 
-	if lvl != nil {
-		lvl.unlinkAlreadyInactive(o)
-		if lvl.head == nil {
-			if side == Bid {
-				_ = b.Bids.DeleteLevel(price)
-			} else {
-				_ = b.Asks.DeleteLevel(price)
-			}
-		}
+// levelOf returns the level currently holding live order o (nil if none).
+func (b *OrderBook) levelOf(o *Order) *PriceLevel {
+	if isStop(o.Type) {
+		return b.stopTree(o.Side).FindLevel(o.StopPrice)
 	}
-	if !rq.Enqueue(o) {
-		panic("retire ring full")
+	return b.tree(o.Side).FindLevel(o.Price)
+}
+
+// canRest reports whether orders of type t may end up resting in the book.
+func canRest(t OrderType) bool {
+	return t == Limit || isPostOnly(t) || t == StopLimit
+}
+
+// tree returns the price tree holding orders of 'side'.
+func (b *OrderBook) tree(side Side) *RBTree {
+	if side == Bid {
+		return b.Bids
 	}
+	return b.Asks
+}
+
+// retire marks o finished, drops it from the ID index and hands it to the
+// reclaimer. Orders that do not fit in the ring are parked until it drains,
+// so a fill in the middle of a sweep never fails.
+func (b *OrderBook) retire(o *Order, rq *retireRing) {
+	o.Status = Inactive
+	o.retireEpoch = globalEpoch.Load()
+	b.index.markDone(o)
+	if len(b.parked) > 0 || !rq.Enqueue(o) {
+		b.parked = append(b.parked, o)
+	}
+}
+
+// retireReady flushes parked orders and reports whether the ring has room
+// for new work (see retireRing.ready). Commands are rejected upfront while
+// it does not.
+func (b *OrderBook) retireReady(rq *retireRing) bool {
+	n := 0
+	for n < len(b.parked) && rq.Enqueue(b.parked[n]) {
+		n++
+	}
+	if n > 0 {
+		k := copy(b.parked, b.parked[n:])
+		clear(b.parked[k:])
+		b.parked = b.parked[:k]
+	}
+	return len(b.parked) == 0 && rq.ready()
+}
+
+// ---------------- Lookup / Cancel by ID ---------------- //
+
+// Lookup returns the live order with 'id', or why there is none.
+func (b *OrderBook) Lookup(id uint64) (*Order, RejectReason) {
+	s := b.index.find(id)
+	switch {
+	case s == nil:
+		return nil, RejectUnknownOrder
+	case s.state == slotDone:
+		return nil, RejectAlreadyDone
+	}
+	return s.o, RejectNone
+}
+
+// CancelByID cancels a resting order knowing only its ID.
+func (b *OrderBook) CancelByID(id uint64, rq *retireRing) RejectReason {
+	o, r := b.Lookup(id)
+	if r != RejectNone {
+		return r
+	}
+	return b.cancelOrder(o.Price, o, rq, o.Side)
 }
 
 // ---------------- FOK Pre-check ---------------- //
 
-// checkLiquidity returns total qty available on the opposite side up to price limit
-func (b *OrderBook) checkLiquidity(side Side, limitPrice int64, desired int64) int64 {
+// checkLiquidity returns the qty FOK order o could fill up to price limit.
+// Like match, it stops at the first level outside the price band. With
+// self-trade prevention on, its own resting orders are skipped if STP
+// removes them (STPCancelOldest); any other mode ends the sweep there, so
+// nothing behind them counts.
+func (b *OrderBook) checkLiquidity(o *Order, limitPrice int64) int64 {
 	available := int64(0)
-	if side == Bid {
-		b.Asks.ForEachAscending(func(lvl *PriceLevel) bool {
-			if lvl.Price > limitPrice {
-				return false
-			}
-			available += lvl.TotalQty
-			if available >= desired {
-				return false
+	selfCheck := b.stp != STPNone && o.Account != 0
+	lo, hi := b.bandRange()
+	visit := func(lvl *PriceLevel) bool {
+		if !crosses(o.Side, limitPrice, lvl.Price) || lvl.Price < lo || lvl.Price > hi {
+			return false
+		}
+		if !selfCheck {
+			available += lvl.TotalQty + lvl.HiddenQty // reserves replenish within one sweep
+			return available < o.Qty
+		}
+		hidden := int64(0)
+		for r := lvl.head; r != nil; r = r.next {
+			if r.Account != o.Account {
+				available += r.Qty
+				hidden += r.hidden
+			} else if b.stp != STPCancelOldest {
+				return false // reserves would refill behind it: out of reach
 			}
-			return true
-		})
+		}
+		available += hidden
+		return available < o.Qty
+	}
+	if o.Side == Bid {
+		b.Asks.ForEachAscending(visit)
 	} else {
-		b.Bids.ForEachDescending(func(lvl *PriceLevel) bool {
-			if lvl.Price < limitPrice {
-				return false
-			}
-			available += lvl.TotalQty
-			if available >= desired {
-				return false
-			}
-			return true
-		})
+		b.Bids.ForEachDescending(visit)
 	}
 	return available
 }
 
+// ---------------- Post-only ---------------- //
+
+func isPostOnly(t OrderType) bool { return t == PostOnly || t == PostOnlySlide }
+
+func isMarket(t OrderType) bool { return t == Market || t == MarketToLimit }
+
+// protectionPrice is the worst price a market order arriving now may trade
+// at: 'ticks' through the opposite best. 0 means unbounded (no protection,
+// or nothing to trade against).
+func (b *OrderBook) protectionPrice(side Side, ticks int64) int64 {
+	best := b.bestOpposite(side)
+	if ticks == 0 || best == nil {
+		return 0
+	}
+	if side == Bid {
+		return best.Price + ticks*b.inst.TickSize
+	}
+	return best.Price - ticks*b.inst.TickSize
+}
+
+// postOnlyPrice checks a post-only price against the opposite best before
+// any fill can happen. PostOnly is rejected if it would cross; PostOnlySlide
+// is repriced one tick behind the opposite best instead.
+func (b *OrderBook) postOnlyPrice(otype OrderType, side Side, price int64) (int64, RejectReason) {
+	best := b.bestOpposite(side)
+	if best == nil || !crosses(side, price, best.Price) {
+		return price, RejectNone
+	}
+	if otype == PostOnly {
+		return 0, RejectWouldCross
+	}
+	if side == Bid {
+		price = best.Price - b.inst.TickSize
+	} else {
+		price = best.Price + b.inst.TickSize
+	}
+	if r := b.inst.checkPrice(price); r != RejectNone {
+		return 0, r
+	}
+	return price, RejectNone
+}
+
 // ---------------- Epoch Reclaim ---------------- //
 
-func advanceEpochAndReclaim(rq *retireRing, pool *OrderPool, rs ...*Reader) {
-	globalEpoch.Add(1)
-	min := minReaderEpoch(rs...)
-	for {
-		o := rq.Dequeue()
-		if o == nil {
-			break
-		}
-		if min == ^uint64(0) || o.retireEpoch < min {
+// advanceEpoch moves to a new epoch and returns the oldest epoch a
+// registered reader is in (^uint64(0) if none), publishing it for the views.
+func advanceEpoch() uint64 {
+	// Advance before scanning: a reader that registers or enters after the
+	// scan sees the new epoch, newer than anything already retired.
+	epoch := globalEpoch.Add(1)
+	min := readers.minEpoch()
+	if min < epoch {
+		safeEpoch.Store(min)
+	} else {
+		safeEpoch.Store(epoch) // no reader: all view memory retired so far is free
+	}
+	return min
+}
+
+// advanceEpochAndReclaim moves to a new epoch and recycles what no
+// registered reader can still see. Matcher thread only; see Reclaimer for
+// reclaiming in the background.
+func advanceEpochAndReclaim(rq *retireRing, pool *OrderPool) {
+	min := advanceEpoch()
+	rq.held.fill(rq)
+	for g := rq.held.pop(min); g != nil; g = rq.held.pop(min) {
+		for _, o := range g {
 			pool.Put(o)
-		} else {
-			_ = rq.Enqueue(o)
-			break
 		}
 	}
 }
 
 // ---------------- Snapshots ---------------- //
 
+// SnapshotActiveIter visits resting orders, bids best first then asks, as
+// of one published feed sequence. Safe on any goroutine: o is a copy rebuilt
+// from the view for each call (valid only during it), never a live order.
 func (b *OrderBook) SnapshotActiveIter(r *Reader, visit func(price int64, o *Order)) {
 	r.EnterRead()
-	// Bids descending (highest first)
-	b.Bids.ForEachDescending(func(lvl *PriceLevel) bool {
-		for n := lvl.head; n != nil; n = n.next {
-			if n.Status == Active {
-				visit(lvl.Price, n)
-			}
-		}
-		return true
-	})
-	// Asks ascending (lowest first)
-	b.Asks.ForEachAscending(func(lvl *PriceLevel) bool {
-		for n := lvl.head; n != nil; n = n.next {
-			if n.Status == Active {
-				visit(lvl.Price, n)
-			}
-		}
-		return true
-	})
+	v := b.view.Load()
+	var o Order
+	each := func(lv *levelView) bool { lv.each(&o, visit); return true }
+	v.bids.each(each)
+	v.asks.each(each)
 	r.ExitRead()
 }
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strconv"
 	"sync/atomic"
 	"testing"
 )
@@ -15,7 +16,7 @@ func BenchmarkPlaceOrder(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = book.placeOrder(Bid, Limit, 100, uint64(i), 1000, seq, pool, rq)
+		_, _ = book.placeOrder(Bid, Limit, 100, uint64(i), 1000, seq, pool, rq)
 		seq++
 	}
 }
@@ -27,7 +28,7 @@ func BenchmarkCancelOrder(b *testing.B) {
 
 	var orders []*Order
 	for i := 0; i < b.N; i++ {
-		o := book.placeOrder(Bid, Limit, 100, uint64(i), 1000, uint64(i+1), pool, rq)
+		o, _ := book.placeOrder(Bid, Limit, 100, uint64(i), 1000, uint64(i+1), pool, rq)
 		orders = append(orders, o)
 	}
 
@@ -45,9 +46,9 @@ func BenchmarkSnapshot(b *testing.B) {
 	// preload book with NON-crossing orders
 	for i := 0; i < 50000; i++ {
 		if i%2 == 0 {
-			_ = book.placeOrder(Bid, Limit, 99, uint64(i), 1000, uint64(i+1), pool, rq)
+			_, _ = book.placeOrder(Bid, Limit, 99, uint64(i), 1000, uint64(i+1), pool, rq)
 		} else {
-			_ = book.placeOrder(Ask, Limit, 101, uint64(i), 1000, uint64(i+1), pool, rq)
+			_, _ = book.placeOrder(Ask, Limit, 101, uint64(i), 1000, uint64(i+1), pool, rq)
 		}
 	}
 	reader := &Reader{}
@@ -64,6 +65,33 @@ func BenchmarkSnapshot(b *testing.B) {
 	}
 }
 
+// BenchmarkDeepBookDelta measures a place and cancel at an existing level
+// halfway down a side 'depth' levels deep: two L2 deltas, each copying one
+// chunk of the side's published level list and the chunk index.
+func BenchmarkDeepBookDelta(b *testing.B) {
+	for _, depth := range []int{10, 1_000, 10_000} {
+		b.Run(strconv.Itoa(depth), func(b *testing.B) {
+			book, pool, rq := NewOrderBook(), NewOrderPool(depth+1<<10), newRetireRing(1<<12)
+			for i := 0; i < depth; i++ {
+				_, _ = book.placeOrder(Bid, Limit, int64(100_000-i), uint64(i+1), 10, uint64(i+1), pool, rq)
+			}
+			mid := int64(100_000 - depth/2)
+			id := uint64(depth + 1)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = book.placeOrder(Bid, Limit, mid, id, 10, id, pool, rq)
+				_ = book.CancelByID(id, rq)
+				id++
+				if i%256 == 255 {
+					advanceEpochAndReclaim(rq, pool)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkMixedPlaceCancel(b *testing.B) {
 	book := NewOrderBook()
 	pool := NewOrderPool(max(b.N*2, 1<<22))
@@ -71,7 +99,7 @@ func BenchmarkMixedPlaceCancel(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		o := book.placeOrder(Bid, Limit, 100, uint64(i), 1000, uint64(i+1), pool, rq)
+		o, _ := book.placeOrder(Bid, Limit, 100, uint64(i), 1000, uint64(i+1), pool, rq)
 		if i%2 == 0 {
 			book.cancelOrder(100, o, rq, Bid)
 		}
@@ -80,54 +108,100 @@ func BenchmarkMixedPlaceCancel(b *testing.B) {
 
 // ---------------- Parallel Versions ---------------- //
 
+// matcherLoop runs 'step' on one goroutine (the single writer) until the
+// returned stop function is called.
+func matcherLoop(step func(i uint64)) (stop func()) {
+	quit, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := uint64(1); ; i++ {
+			select {
+			case <-quit:
+				return
+			default:
+				step(i)
+			}
+		}
+	}()
+	return func() { close(quit); <-done }
+}
+
+// Snapshot readers in parallel with a matcher placing and cancelling.
 func BenchmarkParallelPlaceAndSnapshot(b *testing.B) {
 	book := NewOrderBook()
-	pool := NewOrderPool(max(b.N*2, 1<<22))
-	rq := newRetireRing(uint64(b.N) * 2)
-	seq := uint64(1)
-	reader := &Reader{}
+	pool := NewOrderPool(1 << 16)
+	rq := newRetireRing(1 << 14)
+	stop := matcherLoop(func(i uint64) {
+		_, _ = book.placeOrder(Bid, Limit, int64(90+i%20), i, 1000, i, pool, rq)
+		if i > 500 {
+			_ = book.CancelByID(i-500, rq) // keep ~500 orders resting
+		}
+		advanceEpochAndReclaim(rq, pool)
+	})
 
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
-		localSeq := atomic.AddUint64(&seq, 1)
+		r := RegisterReader()
+		defer UnregisterReader(r)
 		for pb.Next() {
-			if localSeq%2 == 0 {
-				_ = book.placeOrder(Bid, Limit, 100, localSeq, 1000, localSeq, pool, rq)
-			} else {
-				book.SnapshotActiveIter(reader, func(p int64, o *Order) {})
-			}
-			localSeq = atomic.AddUint64(&seq, 1)
+			book.SnapshotActiveIter(r, func(p int64, o *Order) {})
 		}
 	})
+	b.StopTimer()
+	stop()
 }
 
+// Snapshot readers in parallel with a matcher cancelling a deep level.
 func BenchmarkParallelCancelAndSnapshot(b *testing.B) {
 	book := NewOrderBook()
-	pool := NewOrderPool(max(b.N*2, 1<<22))
-	rq := newRetireRing(uint64(b.N) * 2)
-	reader := &Reader{}
-
-	orders := make([]*Order, b.N)
-	for i := 0; i < b.N; i++ {
-		orders[i] = book.placeOrder(Bid, Limit, 100, uint64(i), 1000, uint64(i+1), pool, rq)
+	pool := NewOrderPool(1 << 16)
+	rq := newRetireRing(1 << 14)
+	for i := uint64(1); i <= 1000; i++ {
+		_, _ = book.placeOrder(Bid, Limit, 100, i, 1000, i, pool, rq)
 	}
-	idx := int64(0)
+	stop := matcherLoop(func(i uint64) {
+		_ = book.CancelByID(i, rq)
+		_, _ = book.placeOrder(Bid, Limit, 100, i+1000, 1000, i+1000, pool, rq)
+		advanceEpochAndReclaim(rq, pool)
+	})
 
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
+		r := RegisterReader()
+		defer UnregisterReader(r)
 		for pb.Next() {
-			n := atomic.AddInt64(&idx, 1)
-			if n%2 == 0 {
-				i := int(n) % len(orders)
-				// Only cancel if still active (avoids retireQ overflow / infinite cancels)
-				if orders[i].Status == Active {
-					book.cancelOrder(100, orders[i], rq, Bid)
-				}
-			} else {
-				book.SnapshotActiveIter(reader, func(p int64, o *Order) {})
-			}
+			book.SnapshotActiveIter(r, func(p int64, o *Order) {})
 		}
 	})
+	b.StopTimer()
+	stop()
+}
+
+// Depth snapshots in parallel with a matcher trading through the book.
+func BenchmarkParallelMatchAndDepth(b *testing.B) {
+	book := NewOrderBook()
+	pool := NewOrderPool(1 << 16)
+	rq := newRetireRing(1 << 14)
+	stop := matcherLoop(func(i uint64) {
+		side, price := Bid, int64(100+i%5)
+		if i%2 == 0 {
+			side, price = Ask, int64(96+i%5)
+		}
+		_, _ = book.placeOrder(side, Limit, price, i, int64(1+i%7), i, pool, rq)
+		advanceEpochAndReclaim(rq, pool)
+	})
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := RegisterReader()
+		defer UnregisterReader(r)
+		var d Depth
+		for pb.Next() {
+			book.SnapshotDepth(r, 10, &d)
+		}
+	})
+	b.StopTimer()
+	stop()
 }
 
 // ---------------- Stress Benchmarks ---------------- //
@@ -147,7 +221,7 @@ func BenchmarkThroughputStress(b *testing.B) {
 			side = Ask
 			price = 99 // ensures crossing
 		}
-		_ = book.placeOrder(side, Limit, price, uint64(i), 1, seq, pool, rq)
+		_, _ = book.placeOrder(side, Limit, price, uint64(i), 1, seq, pool, rq)
 		seq++
 	}
 }
@@ -160,12 +234,12 @@ func BenchmarkIOCOrders(b *testing.B) {
 
 	// preload asks so IOC can hit something
 	for i := 0; i < 1000; i++ {
-		_ = book.placeOrder(Ask, Limit, 100, uint64(i), 1, uint64(i+1), pool, rq)
+		_, _ = book.placeOrder(Ask, Limit, 100, uint64(i), 1, uint64(i+1), pool, rq)
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = book.placeOrder(Bid, IOC, 100, uint64(i), 1, atomic.AddUint64(&seq, 1), pool, rq)
+		_, _ = book.placeOrder(Bid, IOC, 100, uint64(1000+i), 1, atomic.AddUint64(&seq, 1), pool, rq)
 	}
 }
 
@@ -177,12 +251,12 @@ func BenchmarkFOKOrders(b *testing.B) {
 
 	// preload small ask depth
 	for i := 0; i < 10; i++ {
-		_ = book.placeOrder(Ask, Limit, 100, uint64(i), 1, uint64(i+1), pool, rq)
+		_, _ = book.placeOrder(Ask, Limit, 100, uint64(i), 1, uint64(i+1), pool, rq)
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = book.placeOrder(Bid, FOK, 100, uint64(i), 20, atomic.AddUint64(&seq, 1), pool, rq)
+		_, _ = book.placeOrder(Bid, FOK, 100, uint64(10+i), 20, atomic.AddUint64(&seq, 1), pool, rq)
 	}
 }
 
@@ -193,7 +267,7 @@ func BenchmarkPostOnlyOrders(b *testing.B) {
 	seq := uint64(1)
 
 	// preload best ask
-	_ = book.placeOrder(Ask, Limit, 100, 1, 1, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 1, 1, pool, rq)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -201,7 +275,7 @@ func BenchmarkPostOnlyOrders(b *testing.B) {
 		if i%2 == 0 {
 			price = 99 // crosses, should reject
 		}
-		_ = book.placeOrder(Bid, PostOnly, price, uint64(i), 1, atomic.AddUint64(&seq, 1), pool, rq)
+		_, _ = book.placeOrder(Bid, PostOnly, price, uint64(2+i), 1, atomic.AddUint64(&seq, 1), pool, rq)
 	}
 }
 
@@ -213,3 +287,22 @@ func max(a, b int) int {
 	}
 	return b
 }
+
+// Gateways placing and cancelling through the matcher's command ring.
+func BenchmarkMatcherGateways(b *testing.B) {
+	e := NewEngine(NewOrderPool(1<<16), newRetireRing(1<<14))
+	e.AddBook("AAPL")
+	m := NewMatcher(e, MatcherConfig{})
+	m.Start()
+	defer m.Stop()
+	var ids atomic.Uint64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id := ids.Add(1)
+			_, _ = m.Do("AAPL", Command{Kind: CmdPlace, Order: OrderSpec{ID: id, Seq: id, Side: Bid, Type: Limit, Price: 100, Qty: 10}})
+			_, _ = m.Do("AAPL", Command{Kind: CmdCancel, ID: id})
+		}
+	})
+}
@@ -10,13 +10,13 @@ func TestLimitOrderInsertAndMatch(t *testing.T) {
 	book, pool, rq := newTestEnv()
 
 	// Place a bid @100
-	bid := book.placeOrder(Bid, Limit, 100, 1, 10, 1, pool, rq)
+	bid, _ := book.placeOrder(Bid, Limit, 100, 1, 10, 1, pool, rq)
 	if bid == nil || bid.Status != Active {
 		t.Fatal("expected active bid order")
 	}
 
 	// Place an ask @100 (crosses immediately)
-	ask := book.placeOrder(Ask, Limit, 100, 2, 10, 2, pool, rq)
+	ask, _ := book.placeOrder(Ask, Limit, 100, 2, 10, 2, pool, rq)
 
 	if bid.Qty != 0 || ask.Qty != 0 {
 		t.Errorf("expected both fully filled, got bidQty=%d askQty=%d", bid.Qty, ask.Qty)
@@ -27,10 +27,10 @@ func TestIOCOrder(t *testing.T) {
 	book, pool, rq := newTestEnv()
 
 	// Add some resting ask @100
-	_ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
 
 	// Place IOC bid @100 qty=10 (only 5 available)
-	bid := book.placeOrder(Bid, IOC, 100, 2, 10, 2, pool, rq)
+	bid, _ := book.placeOrder(Bid, IOC, 100, 2, 10, 2, pool, rq)
 
 	if bid.Status != Inactive {
 		t.Error("IOC should be inactive after placement")
@@ -44,10 +44,10 @@ func TestFOKOrder(t *testing.T) {
 	book, pool, rq := newTestEnv()
 
 	// Only 5 ask liquidity available
-	_ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
 
 	// Place FOK bid @100 qty=10 (not enough liquidity)
-	bid := book.placeOrder(Bid, FOK, 100, 2, 10, 2, pool, rq)
+	bid, _ := book.placeOrder(Bid, FOK, 100, 2, 10, 2, pool, rq)
 
 	if bid.Status != Inactive {
 		t.Error("FOK should be canceled when not fully matched")
@@ -61,29 +61,89 @@ func TestPostOnlyOrder(t *testing.T) {
 	book, pool, rq := newTestEnv()
 
 	// Add ask @100
-	_ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
 
 	// Place PostOnly bid @101 (would cross)
-	bid := book.placeOrder(Bid, PostOnly, 101, 2, 5, 2, pool, rq)
+	bid, r := book.placeOrder(Bid, PostOnly, 101, 2, 5, 2, pool, rq)
 
-	if bid.Status != Inactive {
+	if bid != nil || r != RejectWouldCross {
 		t.Error("PostOnly should be rejected if it crosses")
 	}
+	if lvl := book.Asks.FindLevel(100); lvl == nil || lvl.TotalQty != 5 {
+		t.Error("rejected PostOnly must not execute against the book")
+	}
 
 	// Place PostOnly bid @99 (does not cross, should rest)
-	bid2 := book.placeOrder(Bid, PostOnly, 99, 3, 5, 3, pool, rq)
+	bid2, _ := book.placeOrder(Bid, PostOnly, 99, 3, 5, 3, pool, rq)
 	if bid2.Status != Active {
 		t.Error("PostOnly should rest if it does not cross")
 	}
 }
 
+func TestPostOnlyNoFillBeforeReject(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	rec := &execRecorder{}
+	book.SetExecutionSink(rec)
+	_, _ = book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq)
+
+	// Ask at the bid price would trade → rejected with no execution
+	if _, r := book.placeOrder(Ask, PostOnly, 100, 2, 5, 2, pool, rq); r != RejectWouldCross {
+		t.Errorf("expected would-cross reject, got %v", r)
+	}
+	if len(rec.execs) != 0 {
+		t.Errorf("expected no executions, got %d", len(rec.execs))
+	}
+}
+
+func TestPostOnlySlide(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	rec := &execRecorder{}
+	book.SetExecutionSink(rec)
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 95, 2, 5, 2, pool, rq)
+
+	bid, r := book.placeOrder(Bid, PostOnlySlide, 102, 3, 5, 3, pool, rq)
+	if r != RejectNone || bid.Price != 99 || bid.Status != Active {
+		t.Fatalf("expected bid slid to 99, got %v price=%d", r, bid.Price)
+	}
+	ask, _ := book.placeOrder(Ask, PostOnlySlide, 90, 4, 5, 4, pool, rq)
+	if ask.Price != 100 {
+		t.Errorf("expected ask slid behind best bid 99 to 100, got %d", ask.Price)
+	}
+	// Non-crossing slide orders keep their price
+	bid2, _ := book.placeOrder(Bid, PostOnlySlide, 97, 5, 5, 5, pool, rq)
+	if bid2.Price != 97 {
+		t.Errorf("expected non-crossing price kept, got %d", bid2.Price)
+	}
+	if len(rec.execs) != 0 {
+		t.Errorf("slide orders must never execute on entry, got %d fills", len(rec.execs))
+	}
+}
+
+func TestAmendPostOnlyCross(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
+	po, _ := book.placeOrder(Bid, PostOnly, 98, 2, 5, 2, pool, rq)
+	sl, _ := book.placeOrder(Bid, PostOnlySlide, 97, 3, 5, 3, pool, rq)
+
+	if res, r := book.Amend(2, 100, 5, 4, rq); res != AmendRejected || r != RejectWouldCross {
+		t.Errorf("expected crossing post-only amend rejected, got %v %v", res, r)
+	}
+	if po.Price != 98 || po.Status != Active {
+		t.Error("rejected amend must leave the order untouched")
+	}
+	if res, _ := book.Amend(3, 101, 5, 5, rq); res != AmendRequeued || sl.Price != 99 {
+		t.Errorf("expected slide amend repriced to 99, got %v price=%d", res, sl.Price)
+	}
+}
+
 func TestBidAskSeparation(t *testing.T) {
 	book, pool, rq := newTestEnv()
 
 	// Place bid @99
-	_ = book.placeOrder(Bid, Limit, 99, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 99, 1, 5, 1, pool, rq)
 	// Place ask @101
-	_ = book.placeOrder(Ask, Limit, 101, 2, 5, 2, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 101, 2, 5, 2, pool, rq)
 
 	bestBid := book.Bids.MaxLevel()
 	bestAsk := book.Asks.MinLevel()
@@ -99,13 +159,13 @@ func TestBidAskSeparation(t *testing.T) {
 func TestCancelAndReclaim(t *testing.T) {
 	book, pool, rq := newTestEnv()
 
-	o1 := book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq)
+	o1, _ := book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq)
 	book.cancelOrder(100, o1, rq, Bid)
 	if o1.Status != Inactive {
 		t.Error("expected inactive after cancel")
 	}
 
-	advanceEpochAndReclaim(rq, pool, &Reader{})
+	advanceEpochAndReclaim(rq, pool)
 	if got := pool.Get(); got == nil {
 		t.Error("expected order recycled into pool")
 	} else {
@@ -115,8 +175,8 @@ func TestCancelAndReclaim(t *testing.T) {
 
 func TestSnapshotIter(t *testing.T) {
 	book, pool, rq := newTestEnv()
-	_ = book.placeOrder(Bid, Limit, 99, 1, 5, 1, pool, rq)
-	_ = book.placeOrder(Ask, Limit, 101, 2, 5, 2, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 99, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 101, 2, 5, 2, pool, rq)
 
 	r := &Reader{}
 	var seen []uint64
@@ -128,3 +188,184 @@ func TestSnapshotIter(t *testing.T) {
 		t.Errorf("expected 2 orders in snapshot, got %d", len(seen))
 	}
 }
+
+func TestPlaceOrderValidation(t *testing.T) {
+	book, pool, rq := newTestEnv()
+
+	cases := []struct {
+		otype OrderType
+		price int64
+		qty   int64
+		want  RejectReason
+	}{
+		{Limit, 100, 0, RejectInvalidQty},
+		{Limit, 100, -5, RejectInvalidQty},
+		{Limit, 0, 5, RejectInvalidPrice},
+		{IOC, -1, 5, RejectInvalidPrice},
+		{Market, 0, 5, RejectNone},
+	}
+	for i, c := range cases {
+		_, r := book.placeOrder(Bid, c.otype, c.price, uint64(i+1), c.qty, uint64(i+1), pool, rq)
+		if r != c.want {
+			t.Errorf("case %d: expected %v, got %v", i, c.want, r)
+		}
+	}
+}
+
+func TestPlaceOrderPoolExhausted(t *testing.T) {
+	book, rq := NewOrderBook(), newRetireRing(16)
+	pool := NewOrderPool(1)
+
+	if _, r := book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq); r != RejectNone {
+		t.Fatalf("expected first order accepted, got %v", r)
+	}
+	o, r := book.placeOrder(Bid, Limit, 100, 2, 5, 2, pool, rq)
+	if o != nil || r != RejectPoolExhausted {
+		t.Errorf("expected pool exhausted, got %v %v", o, r)
+	}
+}
+
+func TestRetireRingFullBackpressure(t *testing.T) {
+	book, pool := NewOrderBook(), NewOrderPool(64)
+	rq := newRetireRing(2)
+
+	// Three resting asks swept by one bid: the ring only holds two of the
+	// four retirements, the rest are parked instead of panicking.
+	for i := 1; i <= 3; i++ {
+		_, _ = book.placeOrder(Ask, Limit, 100, uint64(i), 1, uint64(i), pool, rq)
+	}
+	bid, r := book.placeOrder(Bid, Limit, 100, 4, 3, 4, pool, rq)
+	if r != RejectNone || bid.Qty != 0 {
+		t.Fatalf("expected sweep to complete, got %v qty=%d", r, bid.Qty)
+	}
+	if len(book.parked) != 2 {
+		t.Fatalf("expected 2 parked retirements, got %d", len(book.parked))
+	}
+	if _, r := book.placeOrder(Bid, Limit, 90, 5, 1, 5, pool, rq); r != RejectRetireRingFull {
+		t.Errorf("expected retire ring full, got %v", r)
+	}
+
+	// Reclaimer drains twice: parked orders flow in, then trading resumes
+	for i := 0; i < 2; i++ {
+		for rq.Dequeue() != nil {
+		}
+		_ = book.retireReady(rq)
+	}
+	rest, r := book.placeOrder(Bid, Limit, 90, 5, 1, 5, pool, rq)
+	if r != RejectNone {
+		t.Fatalf("expected order accepted after drain, got %v", r)
+	}
+
+	// Ring full again → cancel is refused and the order stays live
+	rq.Enqueue(&Order{})
+	rq.Enqueue(&Order{})
+	if r := book.cancelOrder(90, rest, rq, Bid); r != RejectRetireRingFull {
+		t.Errorf("expected cancel rejected while ring full, got %v", r)
+	}
+	if rest.Status != Active {
+		t.Error("rejected cancel must leave the order live")
+	}
+	for rq.Dequeue() != nil {
+	}
+	if r := book.cancelOrder(90, rest, rq, Bid); r != RejectNone {
+		t.Errorf("expected cancel accepted after drain, got %v", r)
+	}
+	if r := book.cancelOrder(90, rest, rq, Bid); r != RejectAlreadyDone {
+		t.Errorf("expected already done on second cancel, got %v", r)
+	}
+}
+
+// ladder rests qty 5 on 'side' at each price.
+func ladder(book *OrderBook, side Side, firstID uint64, prices []int64, pool *OrderPool, rq *retireRing) {
+	for i, p := range prices {
+		id := firstID + uint64(i)
+		_, _ = book.placeOrder(side, Limit, p, id, 5, id, pool, rq)
+	}
+}
+
+func TestMarketProtectionStopsSweep(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	ladder(book, Ask, 1, []int64{100, 101, 103, 106}, pool, rq)
+
+	o, r := book.submit(OrderSpec{ID: 10, Seq: 10, Side: Bid, Type: Market, Qty: 20, Protect: 3}, pool, rq)
+	if r != RejectNone {
+		t.Fatal(r)
+	}
+	if o.Filled != 15 || o.Status != Inactive {
+		t.Errorf("filled %d (status %d), want 15 up to 103 and the rest cancelled", o.Filled, o.Status)
+	}
+	if lvl := book.Asks.MinLevel(); lvl == nil || lvl.Price != 106 || lvl.TotalQty != 5 {
+		t.Error("protected market order traded through its protection price")
+	}
+}
+
+func TestMarketProtectionInTicks(t *testing.T) {
+	book := NewOrderBookFor(Instrument{TickSize: 5})
+	pool, rq := NewOrderPool(64), newRetireRing(64)
+	ladder(book, Bid, 1, []int64{500, 495, 485}, pool, rq)
+
+	// Two ticks below 500 is 490: 485 is out of reach.
+	o, _ := book.submit(OrderSpec{ID: 10, Seq: 10, Side: Ask, Type: Market, Qty: 15, Protect: 2}, pool, rq)
+	if o.Filled != 10 {
+		t.Errorf("filled %d, want 10", o.Filled)
+	}
+	if lvl := book.Bids.MaxLevel(); lvl == nil || lvl.Price != 485 {
+		t.Error("expected the 485 bid untouched")
+	}
+}
+
+func TestMarketToLimitRestsAtLastFill(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	ladder(book, Ask, 1, []int64{100, 101}, pool, rq)
+	var prices []int64
+	book.SetExecutionSink(ExecutionSinkFunc(func(e Execution) { prices = append(prices, e.Price) }))
+
+	o, _ := book.submit(OrderSpec{ID: 10, Seq: 10, Side: Bid, Type: MarketToLimit, Qty: 15}, pool, rq)
+	if len(prices) != 2 || prices[1] != 101 {
+		t.Fatalf("executions at %v, want 100 then 101", prices)
+	}
+	if o.Type != Limit || o.Price != 101 || o.Qty != 5 || o.Status != Active {
+		t.Errorf("remainder = %+v, want a resting limit of 5 at 101", *o)
+	}
+	if lvl := book.Bids.MaxLevel(); lvl == nil || lvl.Price != 101 || lvl.TotalQty != 5 {
+		t.Error("remainder not on the bid side at 101")
+	}
+	if res, r := book.Amend(10, 101, 3, 11, rq); res != AmendedInPlace || r != RejectNone {
+		t.Errorf("resting remainder not amendable: %v %v", res, r)
+	}
+}
+
+func TestMarketToLimitWithProtection(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	ladder(book, Ask, 1, []int64{100, 101, 104}, pool, rq)
+
+	// Protected at 102, so it stops before 104 and rests at its last fill.
+	o, _ := book.submit(OrderSpec{ID: 10, Seq: 10, Side: Bid, Type: MarketToLimit, Qty: 20, Protect: 2}, pool, rq)
+	if o.Filled != 10 || o.Price != 101 || o.Qty != 10 {
+		t.Errorf("filled %d, resting %d at %d; want 10 resting at 101", o.Filled, o.Qty, o.Price)
+	}
+	bid, ask := book.Bids.MaxLevel(), book.Asks.MinLevel()
+	if bid == nil || ask == nil || bid.Price >= ask.Price {
+		t.Error("book left crossed")
+	}
+}
+
+func TestMarketToLimitNoLiquidityCancelled(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	o, r := book.submit(OrderSpec{ID: 1, Seq: 1, Side: Bid, Type: MarketToLimit, Qty: 5}, pool, rq)
+	if r != RejectNone || o.Status != Inactive || book.Bids.MaxLevel() != nil {
+		t.Error("market-to-limit with nothing to trade should be cancelled, not rest")
+	}
+}
+
+func TestProtectionValidation(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	for _, s := range []OrderSpec{
+		{ID: 1, Seq: 1, Side: Bid, Type: Limit, Price: 100, Qty: 5, Protect: 2},
+		{ID: 2, Seq: 2, Side: Bid, Type: Market, Qty: 5, Protect: -1},
+	} {
+		if _, r := book.submit(s, pool, rq); r != RejectProtection {
+			t.Errorf("order %d: expected RejectProtection, got %v", s.ID, r)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+// orderIndex maps order ID → *Order for O(1) cancel/lookup by ID.
+// - Open addressing with linear probing over preallocated slot arrays.
+// - Finished orders leave a 'done' marker so a late cancel can be told
+//   apart from an unknown ID; markers are dropped on compaction.
+// - Compaction rehashes into a spare array of the same size, so the
+//   index only allocates when the live set outgrows half the capacity.
+
+type slotState uint8
+
+const (
+	slotEmpty slotState = iota
+	slotLive
+	slotDone
+)
+
+type indexSlot struct {
+	id    uint64
+	o     *Order
+	state slotState
+}
+
+type orderIndex struct {
+	slots []indexSlot
+	spare []indexSlot // compaction target, same length as slots
+	mask  uint64
+	live  int
+	done  int
+}
+
+const defaultIndexCap = 1 << 12
+
+func newOrderIndex(pow2 int) *orderIndex {
+	return &orderIndex{
+		slots: make([]indexSlot, pow2),
+		spare: make([]indexSlot, pow2),
+		mask:  uint64(pow2 - 1),
+	}
+}
+
+// hashID spreads sequential IDs across the table (splitmix64 finalizer).
+func hashID(id uint64) uint64 {
+	id ^= id >> 30
+	id *= 0xbf58476d1ce4e5b9
+	id ^= id >> 27
+	id *= 0x94d049bb133111eb
+	id ^= id >> 31
+	return id
+}
+
+// find returns the slot holding id (live or done), or nil.
+func (x *orderIndex) find(id uint64) *indexSlot {
+	for i := hashID(id) & x.mask; ; i = (i + 1) & x.mask {
+		s := &x.slots[i]
+		if s.state == slotEmpty {
+			return nil
+		}
+		if s.id == id {
+			return s
+		}
+	}
+}
+
+// insert adds o as live. Returns false if a live order already owns o.ID.
+func (x *orderIndex) insert(o *Order) bool {
+	if s := x.find(o.ID); s != nil {
+		if s.state == slotLive {
+			return false
+		}
+		s.o, s.state = o, slotLive
+		x.done--
+		x.live++
+		return true
+	}
+	if (x.live+x.done+1)*4 > len(x.slots)*3 {
+		x.rebuild()
+	}
+	for i := hashID(o.ID) & x.mask; ; i = (i + 1) & x.mask {
+		s := &x.slots[i]
+		if s.state == slotEmpty {
+			*s = indexSlot{id: o.ID, o: o, state: slotLive}
+			x.live++
+			return true
+		}
+	}
+}
+
+// markDone flags o's entry as finished; no-op if o is not the indexed order.
+func (x *orderIndex) markDone(o *Order) {
+	s := x.find(o.ID)
+	if s == nil || s.state != slotLive || s.o != o {
+		return
+	}
+	s.o, s.state = nil, slotDone
+	x.live--
+	x.done++
+}
+
+// rebuild drops done markers, doubling the table if live orders need the room.
+func (x *orderIndex) rebuild() {
+	if (x.live+1)*2 > len(x.slots) {
+		n := len(x.slots) * 2
+		x.spare = make([]indexSlot, n)
+	}
+	old := x.slots
+	x.slots, x.spare = x.spare, old
+	x.mask = uint64(len(x.slots) - 1)
+	clear(x.slots)
+	x.done = 0
+	for i := range old {
+		if old[i].state != slotLive {
+			continue
+		}
+		for j := hashID(old[i].id) & x.mask; ; j = (j + 1) & x.mask {
+			if x.slots[j].state == slotEmpty {
+				x.slots[j] = old[i]
+				break
+			}
+		}
+	}
+	if len(x.spare) != len(x.slots) {
+		x.spare = make([]indexSlot, len(x.slots))
+	} else {
+		clear(x.spare)
+	}
+}
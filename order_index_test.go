@@ -0,0 +1,139 @@
+package main
+
+import "testing"
+
+func TestOrderIndexInsertFindDone(t *testing.T) {
+	x := newOrderIndex(8)
+	o1 := &Order{ID: 1}
+	o2 := &Order{ID: 2}
+
+	if !x.insert(o1) || !x.insert(o2) {
+		t.Fatal("insert failed unexpectedly")
+	}
+	if x.insert(&Order{ID: 1}) {
+		t.Error("expected duplicate live ID to be refused")
+	}
+	if s := x.find(2); s == nil || s.o != o2 {
+		t.Error("find did not return o2")
+	}
+
+	x.markDone(o1)
+	if s := x.find(1); s == nil || s.state != slotDone {
+		t.Error("expected done marker for ID 1")
+	}
+	// ID may be reused once the previous owner is done
+	o1b := &Order{ID: 1}
+	if !x.insert(o1b) || x.find(1).o != o1b {
+		t.Error("expected ID 1 to be reusable after done")
+	}
+}
+
+func TestOrderIndexMarkDoneIgnoresStranger(t *testing.T) {
+	x := newOrderIndex(8)
+	o := &Order{ID: 7}
+	x.insert(o)
+	x.markDone(&Order{ID: 7}) // same ID, different order
+	if s := x.find(7); s.state != slotLive || s.o != o {
+		t.Error("markDone must only affect the indexed order")
+	}
+}
+
+func TestOrderIndexGrowAndCompact(t *testing.T) {
+	x := newOrderIndex(8)
+	orders := make([]*Order, 100)
+	for i := range orders {
+		orders[i] = &Order{ID: uint64(i)}
+		if !x.insert(orders[i]) {
+			t.Fatalf("insert %d failed", i)
+		}
+	}
+	for i := range orders {
+		if s := x.find(uint64(i)); s == nil || s.o != orders[i] {
+			t.Fatalf("lost ID %d after growth", i)
+		}
+	}
+
+	// Churn: done markers must not grow the table
+	size := len(x.slots)
+	for i := range orders {
+		x.markDone(orders[i])
+	}
+	for i := 0; i < 10*size; i++ {
+		o := &Order{ID: uint64(1000 + i)}
+		x.insert(o)
+		x.markDone(o)
+	}
+	if len(x.slots) != size {
+		t.Errorf("expected capacity to stay %d under churn, got %d", size, len(x.slots))
+	}
+	if x.live != 0 {
+		t.Errorf("expected no live entries, got %d", x.live)
+	}
+}
+
+func TestCancelByID(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	o, _ := book.placeOrder(Bid, Limit, 100, 42, 5, 1, pool, rq)
+
+	if got, r := book.Lookup(42); r != RejectNone || got != o {
+		t.Fatalf("expected lookup to find order 42, got %v %v", got, r)
+	}
+	if r := book.CancelByID(42, rq); r != RejectNone {
+		t.Fatalf("expected cancel accepted, got %v", r)
+	}
+	if o.Status != Inactive || book.Bids.FindLevel(100) != nil {
+		t.Error("expected order removed from book")
+	}
+	if r := book.CancelByID(42, rq); r != RejectAlreadyDone {
+		t.Errorf("expected already done, got %v", r)
+	}
+	if r := book.CancelByID(99, rq); r != RejectUnknownOrder {
+		t.Errorf("expected unknown order, got %v", r)
+	}
+}
+
+func TestLookupAfterFill(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 100, 2, 5, 2, pool, rq)
+
+	for _, id := range []uint64{1, 2} {
+		if _, r := book.Lookup(id); r != RejectAlreadyDone {
+			t.Errorf("order %d: expected already done after fill, got %v", id, r)
+		}
+	}
+}
+
+func TestPlaceDuplicateLiveID(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	first, _ := book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq)
+	dup, r := book.placeOrder(Bid, Limit, 101, 1, 5, 2, pool, rq)
+
+	if dup != nil || r != RejectDuplicateID {
+		t.Errorf("expected duplicate ID reject, got %v %v", dup, r)
+	}
+	if book.Bids.FindLevel(101) != nil {
+		t.Error("rejected order must not reach the book")
+	}
+	if got, _ := book.Lookup(1); got != first {
+		t.Error("expected original order to keep its ID")
+	}
+}
+
+func BenchmarkPlaceCancelByIDAllocs(b *testing.B) {
+	book := NewOrderBook()
+	pool := NewOrderPool(1 << 12)
+	rq := newRetireRing(1 << 12)
+	b.ReportAllocs()
+
+	// keep the level alive so only the index is exercised
+	_, _ = book.placeOrder(Bid, Limit, 100, 0, 10, 0, pool, rq)
+
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		id := uint64(i)
+		_, _ = book.placeOrder(Bid, Limit, 100, id, 10, id, pool, rq)
+		_ = book.CancelByID(id, rq)
+		advanceEpochAndReclaim(rq, pool)
+	}
+}
@@ -1,10 +1,15 @@
 package main
 
 type PriceLevel struct {
-	Price    int64
-	head     *Order
-	tail     *Order
-	TotalQty int64
+	Price     int64
+	head      *Order
+	tail      *Order
+	TotalQty  int64 // displayed quantity
+	HiddenQty int64 // iceberg reserves behind the displayed quantity
+	Count     int   // orders in the queue
+
+	view   *levelView // newest snapshot version of this level (matcher only)
+	staged bool       // view has unpublished changes
 }
 
 func (lvl *PriceLevel) Enqueue(o *Order) {
@@ -16,6 +21,8 @@ func (lvl *PriceLevel) Enqueue(o *Order) {
 	}
 	lvl.tail = o
 	lvl.TotalQty += o.Qty
+	lvl.HiddenQty += o.hidden
+	lvl.Count++
 }
 
 func (lvl *PriceLevel) unlinkAlreadyInactive(o *Order) {
@@ -30,5 +37,32 @@ func (lvl *PriceLevel) unlinkAlreadyInactive(o *Order) {
 		lvl.tail = o.prev
 	}
 	lvl.TotalQty -= o.Qty
+	lvl.HiddenQty -= o.hidden
+	lvl.Count--
 	o.next, o.prev = nil, nil
 }
+
+// Reduce lowers o's open quantity (displayed + hidden) to qty in place,
+// keeping its FIFO position. Hidden reserve is consumed first.
+func (lvl *PriceLevel) Reduce(o *Order, qty int64) {
+	cut := o.Qty + o.hidden - qty
+	h := min(cut, o.hidden)
+	o.hidden -= h
+	lvl.HiddenQty -= h
+	cut -= h
+	o.Qty -= cut
+	lvl.TotalQty -= cut
+}
+
+// Replenish refills an iceberg whose displayed slice was fully filled and
+// sends it to the back of the queue. Returns false if no reserve is left.
+func (lvl *PriceLevel) Replenish(o *Order) bool {
+	if o.hidden == 0 {
+		return false
+	}
+	lvl.unlinkAlreadyInactive(o)
+	refill := min(o.display, o.hidden)
+	o.Qty, o.hidden = refill, o.hidden-refill
+	lvl.Enqueue(o)
+	return true
+}
@@ -20,3 +20,19 @@ func TestPriceLevelFIFO(t *testing.T) {
 		t.Error("expected o2 to become head after unlinking o1")
 	}
 }
+
+func TestPriceLevelReduce(t *testing.T) {
+	lvl := &PriceLevel{Price: 100}
+	o1 := &Order{ID: 1, Qty: 10}
+	o2 := &Order{ID: 2, Qty: 5}
+	lvl.Enqueue(o1)
+	lvl.Enqueue(o2)
+
+	lvl.Reduce(o1, 3)
+	if o1.Qty != 3 || lvl.TotalQty != 8 {
+		t.Errorf("expected qty=3 total=8, got qty=%d total=%d", o1.Qty, lvl.TotalQty)
+	}
+	if lvl.head != o1 {
+		t.Error("reduce must not change FIFO order")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------- Background reclaimer ---------------- //
+//
+// A Reclaimer takes epoch advancing and order recycling off the matcher.
+// It is the consumer of each retire ring it serves: orders are drained into
+// the ring's limbo as soon as they arrive and held there until no reader
+// can see them, so a pinned reader never backs the ring up. Recycled orders go back through the
+// pool's return ring (see OrderPool.giveBack), which Get drains on the
+// matcher thread; the pool itself stays single-threaded.
+//
+// Each ring keeps a reserve of free slots. Once no more than the reserve
+// is free the matcher rejects new commands with RejectRetireRingFull, so
+// the fills of a command already running always fit; at twice the reserve
+// the reclaimer is woken ahead of its cadence.
+
+type ReclaimerConfig struct {
+	Interval time.Duration // epoch cadence (default 1ms)
+	Reserve  uint64        // free ring slots kept for commands in flight (default 1/8 ring)
+}
+
+type reclaimTarget struct {
+	pool *OrderPool
+	rq   *retireRing
+}
+
+type Reclaimer struct {
+	cfg     ReclaimerConfig
+	mu      sync.Mutex // guards targets against Add while running
+	targets []*reclaimTarget
+	wake    chan struct{}
+	quit    chan struct{}
+	done    chan struct{}
+	passes  atomic.Uint64
+	held    atomic.Int64
+}
+
+func NewReclaimer(cfg ReclaimerConfig) *Reclaimer {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Millisecond
+	}
+	return &Reclaimer{cfg: cfg, wake: make(chan struct{}, 1)}
+}
+
+// Add puts a pool/ring pair under the reclaimer. Call it on the matcher
+// thread, before or after Start; from then on nothing else may consume rq.
+func (rc *Reclaimer) Add(pool *OrderPool, rq *retireRing) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, t := range rc.targets {
+		if t.rq == rq {
+			return
+		}
+	}
+	pool.share()
+	rq.reserve = rc.cfg.Reserve
+	if rq.reserve == 0 {
+		rq.reserve = uint64(len(rq.buf)) / 8
+	}
+	rq.wake = rc.wake
+	rc.targets = append(rc.targets, &reclaimTarget{pool: pool, rq: rq})
+}
+
+// Start runs the reclaimer on its own goroutine until Stop.
+func (rc *Reclaimer) Start() {
+	rc.quit, rc.done = make(chan struct{}), make(chan struct{})
+	go rc.run()
+}
+
+// Stop ends the goroutine after its current pass.
+func (rc *Reclaimer) Stop() {
+	close(rc.quit)
+	<-rc.done
+}
+
+// Kick asks for a pass now rather than at the next tick.
+func (rc *Reclaimer) Kick() {
+	select {
+	case rc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Passes returns the number of completed passes.
+func (rc *Reclaimer) Passes() uint64 { return rc.passes.Load() }
+
+// Held returns the number of orders drained but not yet recycled.
+func (rc *Reclaimer) Held() int { return int(rc.held.Load()) }
+
+func (rc *Reclaimer) run() {
+	defer close(rc.done)
+	tick := time.NewTicker(rc.cfg.Interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-rc.quit:
+			return
+		case <-tick.C:
+		case <-rc.wake:
+		}
+		rc.pass()
+	}
+}
+
+// pass advances the epoch and recycles everything no reader can see.
+func (rc *Reclaimer) pass() {
+	min := advanceEpoch()
+	rc.mu.Lock()
+	var held int
+	for _, t := range rc.targets {
+		t.sweep(min)
+		held += t.rq.held.Len()
+	}
+	rc.mu.Unlock()
+	rc.held.Store(int64(held))
+	rc.passes.Add(1)
+}
+
+// sweep drains the ring, then gives back every order retired before 'min'.
+func (t *reclaimTarget) sweep(min uint64) {
+	t.rq.held.fill(t.rq)
+	for g := t.rq.held.pop(min); g != nil; g = t.rq.held.pop(min) {
+		for _, o := range g {
+			t.pool.giveBack(o)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds, failing the test after a while.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(50 * time.Microsecond)
+	}
+}
+
+// placeRetrying places a limit order, waiting out resource rejects.
+func placeRetrying(book *OrderBook, side Side, price int64, id uint64, pool *OrderPool, rq *retireRing) RejectReason {
+	for {
+		_, r := book.placeOrder(side, Limit, price, id, 5, id, pool, rq)
+		if r != RejectRetireRingFull && r != RejectPoolExhausted {
+			return r
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+}
+
+func cancelRetrying(book *OrderBook, id uint64, rq *retireRing) RejectReason {
+	for {
+		if r := book.CancelByID(id, rq); r != RejectRetireRingFull {
+			return r
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+}
+
+func TestReclaimerRecyclesInBackground(t *testing.T) {
+	book, pool, rq := NewOrderBook(), NewOrderPool(64), newRetireRing(64)
+	rc := NewReclaimer(ReclaimerConfig{Interval: 100 * time.Microsecond})
+	rc.Add(pool, rq)
+	rc.Start()
+	defer rc.Stop()
+
+	for id := uint64(1); id <= 5_000; id++ { // far more than the pool holds
+		if r := placeRetrying(book, Bid, 100, id, pool, rq); r != RejectNone {
+			t.Fatalf("place %d: %v", id, r)
+		}
+		if r := cancelRetrying(book, id, rq); r != RejectNone {
+			t.Fatalf("cancel %d: %v", id, r)
+		}
+	}
+	waitFor(t, "every order back in the pool", func() bool { return pool.Available() == 64 })
+}
+
+func TestReclaimerBackpressureBeforeFull(t *testing.T) {
+	book, pool, rq := NewOrderBook(), NewOrderPool(64), newRetireRing(16)
+	rc := NewReclaimer(ReclaimerConfig{Reserve: 4})
+	rc.Add(pool, rq) // not started: nothing drains the ring
+
+	var id uint64
+	for {
+		id++
+		if _, r := book.placeOrder(Bid, Limit, 100, id, 5, id, pool, rq); r != RejectNone {
+			if r != RejectRetireRingFull {
+				t.Fatalf("expected retire ring full, got %v", r)
+			}
+			break
+		}
+		_ = book.CancelByID(id, rq)
+	}
+	if rq.Free() != 4 {
+		t.Errorf("pushed back with %d slots free, want the reserve of 4", rq.Free())
+	}
+	select {
+	case <-rc.wake:
+	default:
+		t.Error("reclaimer was not woken as the ring filled")
+	}
+
+	rc.pass()
+	if _, r := book.placeOrder(Bid, Limit, 100, id, 5, id, pool, rq); r != RejectNone {
+		t.Errorf("expected order accepted after a pass, got %v", r)
+	}
+	if pool.Available() != 63 {
+		t.Errorf("expected recycled orders back in the pool, %d free", pool.Available())
+	}
+}
+
+// A pinned reader holds orders back in the reclaimer, not in the ring, so
+// the matcher keeps going.
+func TestReclaimerHoldsForPinnedReader(t *testing.T) {
+	book, pool, rq := NewOrderBook(), NewOrderPool(256), newRetireRing(16)
+	rc := NewReclaimer(ReclaimerConfig{Interval: 100 * time.Microsecond, Reserve: 2})
+	rc.Add(pool, rq)
+	rc.Start()
+	defer rc.Stop()
+
+	r := RegisterReader()
+	defer UnregisterReader(r)
+	r.EnterRead()
+	for id := uint64(1); id <= 100; id++ { // several times the ring
+		if res := placeRetrying(book, Ask, 100, id, pool, rq); res != RejectNone {
+			t.Fatalf("place %d: %v", id, res)
+		}
+		if res := cancelRetrying(book, id, rq); res != RejectNone {
+			t.Fatalf("cancel %d: %v", id, res)
+		}
+	}
+	waitFor(t, "ring drained into the reclaimer", func() bool { return rc.Held() == 100 })
+	if pool.Available() != 156 {
+		t.Fatalf("orders recycled under a pinned reader: %d free", pool.Available())
+	}
+
+	r.ExitRead()
+	waitFor(t, "held orders recycled", func() bool { return pool.Available() == 256 })
+	if rc.Held() != 0 {
+		t.Errorf("%d orders still held", rc.Held())
+	}
+}
+
+// Matcher, reclaimer and snapshot readers all on their own goroutines.
+// Run with -race.
+func TestReclaimerWithConcurrentReaders(t *testing.T) {
+	book, pool, rq := NewOrderBook(), NewOrderPool(512), newRetireRing(64)
+	rc := NewReclaimer(ReclaimerConfig{Interval: 50 * time.Microsecond})
+	rc.Add(pool, rq)
+	rc.Start()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		r := RegisterReader()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer UnregisterReader(r)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				book.SnapshotActiveIter(r, func(int64, *Order) {})
+				runtime.Gosched()
+			}
+		}()
+	}
+
+	for id := uint64(1); id <= 5_000; id++ {
+		side, price := Bid, int64(95+id%5)
+		if id%2 == 0 {
+			side, price = Ask, int64(97+id%5)
+		}
+		_ = placeRetrying(book, side, price, id, pool, rq)
+		if id > 100 { // at most 100 live; the rest of the pool churns
+			_ = cancelRetrying(book, id-100, rq)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	rc.Stop()
+
+	rc.pass() // settle what the last pass left
+	if got := pool.Available() + book.index.live + len(book.parked) + rc.Held(); got != 512 {
+		t.Errorf("orders lost: %d free + %d live + %d parked + %d held, want 512",
+			pool.Available(), book.index.live, len(book.parked), rc.Held())
+	}
+}
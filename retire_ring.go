@@ -2,7 +2,8 @@ package main
 
 import "sync/atomic"
 
-// SPSC ring for retired orders (matcher→reclaimer).
+// SPSC ring for retired orders (matcher→reclaimer). Also carries recycled
+// orders back (reclaimer→pool).
 type retireRing struct {
 	// align head/tail to separate cache lines
 	head  uint64
@@ -12,6 +13,12 @@ type retireRing struct {
 
 	buf  []*Order
 	mask uint64
+
+	// Backpressure, set by a Reclaimer before the ring is shared.
+	reserve uint64          // producer takes no new work with this few slots free
+	wake    chan<- struct{} // nudges the consumer as the ring fills
+
+	held limbo // consumer side: drained, waiting for readers to move on
 }
 
 func newRetireRing(pow2 uint64) *retireRing {
@@ -25,7 +32,7 @@ func (q *retireRing) Enqueue(o *Order) bool {
 		return false // full
 	}
 	q.buf[h&q.mask] = o
-	q.head = h + 1
+	atomic.StoreUint64(&q.head, h+1)
 	return true
 }
 
@@ -37,6 +44,30 @@ func (q *retireRing) Dequeue() *Order {
 	}
 	o := q.buf[t&q.mask]
 	q.buf[t&q.mask] = nil
-	q.tail = t + 1
+	atomic.StoreUint64(&q.tail, t+1)
 	return o
 }
+
+// Free returns the number of slots the producer can still fill.
+func (q *retireRing) Free() uint64 {
+	return uint64(len(q.buf)) - (q.head - atomic.LoadUint64(&q.tail))
+}
+
+// Len returns the number of queued orders (either side).
+func (q *retireRing) Len() uint64 {
+	return atomic.LoadUint64(&q.head) - atomic.LoadUint64(&q.tail)
+}
+
+// ready reports whether the producer may take new work: more than the
+// reserve is still free, so fills of a command in flight always fit. The
+// consumer is woken once the ring is down to twice the reserve.
+func (q *retireRing) ready() bool {
+	free := q.Free()
+	if q.wake != nil && free <= 2*q.reserve {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return free > q.reserve
+}
@@ -20,3 +20,40 @@ func TestRetireRingBasic(t *testing.T) {
 		t.Error("expected empty ring to return nil")
 	}
 }
+
+func TestRetireRingFree(t *testing.T) {
+	r := newRetireRing(2)
+	if r.Free() != 2 {
+		t.Errorf("expected 2 free slots, got %d", r.Free())
+	}
+	r.Enqueue(&Order{ID: 1})
+	r.Enqueue(&Order{ID: 2})
+	if r.Free() != 0 || r.Enqueue(&Order{ID: 3}) {
+		t.Error("expected full ring to refuse enqueue")
+	}
+	r.Dequeue()
+	if r.Free() != 1 {
+		t.Errorf("expected 1 free slot after dequeue, got %d", r.Free())
+	}
+}
+
+func TestRetireRingReserve(t *testing.T) {
+	wake := make(chan struct{}, 1)
+	r := newRetireRing(8)
+	r.reserve, r.wake = 2, wake
+	for i := 0; i < 3; i++ {
+		r.Enqueue(&Order{ID: uint64(i)})
+	}
+	if !r.ready() || len(wake) != 0 {
+		t.Fatal("half-empty ring should take work without waking the consumer")
+	}
+	r.Enqueue(&Order{ID: 3})
+	if !r.ready() || len(wake) != 1 {
+		t.Error("consumer should be woken at twice the reserve")
+	}
+	r.Enqueue(&Order{ID: 4})
+	r.Enqueue(&Order{ID: 5})
+	if r.ready() {
+		t.Error("producer should be pushed back once only the reserve is free")
+	}
+}
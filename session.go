@@ -0,0 +1,141 @@
+package main
+
+import "errors"
+
+// ---------------- Trading session ---------------- //
+//
+// Each book runs a session state machine. Matching only happens in
+// Continuous; in every other state the book is in a call (see auction.go)
+// and nothing trades. Entering Continuous uncrosses whatever accumulated,
+// and so does closing out of the closing auction (the closing price).
+// The session is the only switch: StartAuction and Uncross are shorthands
+// for transitions and follow the same table.
+//
+//	state              new orders          amends  cancels
+//	PreOpen            no                  yes     yes
+//	OpeningAuction     limits and stops    yes     yes
+//	Continuous         all                 yes     yes
+//	Halted             no                  no      yes
+//	ClosingAuction     limits and stops    yes     yes
+//	Closed             no                  no      yes
+//	VolatilityAuction  limits and stops    yes     yes
+//
+// A new book starts in Continuous.
+
+type SessionState uint8
+
+const (
+	Continuous SessionState = iota
+	PreOpen
+	OpeningAuction
+	Halted
+	ClosingAuction
+	Closed
+	VolatilityAuction // entered on a price band breach (see bands.go)
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case Continuous:
+		return "continuous"
+	case PreOpen:
+		return "pre-open"
+	case OpeningAuction:
+		return "opening auction"
+	case Halted:
+		return "halted"
+	case ClosingAuction:
+		return "closing auction"
+	case Closed:
+		return "closed"
+	case VolatilityAuction:
+		return "volatility auction"
+	}
+	return "unknown"
+}
+
+var ErrSessionTransition = errors.New("session: transition not allowed")
+
+// sessionNext lists the states each state may move to.
+var sessionNext = [...][]SessionState{
+	Continuous:        {Halted, ClosingAuction, Closed, VolatilityAuction},
+	PreOpen:           {OpeningAuction, Continuous, Halted, Closed},
+	OpeningAuction:    {Continuous, Halted},
+	Halted:            {OpeningAuction, Continuous, ClosingAuction, Closed},
+	ClosingAuction:    {Closed, Halted},
+	Closed:            {PreOpen},
+	VolatilityAuction: {Continuous, Halted},
+}
+
+// SessionEvent reports a state change.
+type SessionEvent struct {
+	Seq     uint64 // per book, from 1
+	From    SessionState
+	To      SessionState
+	Time    int64         // book clock
+	Uncross AuctionResult // executed on the way (zero if none)
+}
+
+// SessionSink receives session changes on the matcher thread.
+type SessionSink interface {
+	OnSession(e SessionEvent)
+}
+
+// SessionSinkFunc adapts a plain function to SessionSink.
+type SessionSinkFunc func(e SessionEvent)
+
+func (f SessionSinkFunc) OnSession(e SessionEvent) { f(e) }
+
+// SetSessionSink installs the sink for session changes (nil disables).
+func (b *OrderBook) SetSessionSink(s SessionSink) { b.sessionSink = s }
+
+// Session returns the current state.
+func (b *OrderBook) Session() SessionState { return b.session }
+
+// SetSession moves the book to state 'to', uncrossing on the way into
+// Continuous or out of the closing auction.
+func (b *OrderBook) SetSession(to SessionState, rq *retireRing) (AuctionResult, error) {
+	from := b.session
+	allowed := false
+	for _, s := range sessionNext[from] {
+		allowed = allowed || s == to
+	}
+	if !allowed {
+		return AuctionResult{}, ErrSessionTransition
+	}
+	b.session = to
+	b.sessionSeq++
+	var res AuctionResult
+	if to == Continuous || (from == ClosingAuction && to == Closed) {
+		res = b.uncross(rq)
+	}
+	if b.sessionSink != nil {
+		b.sessionSink.OnSession(SessionEvent{
+			Seq: b.sessionSeq, From: from, To: to, Time: b.clock.Now(), Uncross: res,
+		})
+	}
+	b.releaseStops(rq) // Continuous only: stops stay pending out of the close
+	return res, nil
+}
+
+// calling reports whether the book is in a call: outside Continuous
+// nothing matches.
+func (b *OrderBook) calling() bool { return b.session != Continuous }
+
+// acceptsOrders reports whether new orders of type t may enter now.
+func (b *OrderBook) acceptsOrders(t OrderType) RejectReason {
+	switch b.session {
+	case Continuous, OpeningAuction, ClosingAuction, VolatilityAuction:
+	default:
+		return RejectSessionState
+	}
+	if b.calling() && !joinsCall(t) {
+		return RejectAuctionCall
+	}
+	return RejectNone
+}
+
+// acceptsAmends reports whether resting orders may be amended now.
+func (b *OrderBook) acceptsAmends() bool {
+	return b.session != Halted && b.session != Closed
+}
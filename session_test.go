@@ -0,0 +1,171 @@
+package main
+
+import "testing"
+
+func TestSessionDayCycle(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	var events []SessionEvent
+	book.SetSessionSink(SessionSinkFunc(func(e SessionEvent) { events = append(events, e) }))
+
+	step := func(to SessionState) AuctionResult {
+		t.Helper()
+		res, err := book.SetSession(to, rq)
+		if err != nil {
+			t.Fatalf("%v -> %v: %v", book.Session(), to, err)
+		}
+		return res
+	}
+	step(Closed)
+	step(PreOpen)
+	step(OpeningAuction)
+	_, _ = book.placeOrder(Bid, Limit, 101, 1, 10, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 2, 4, 2, pool, rq)
+	if open := step(Continuous); open.Price != 100 || open.Volume != 4 {
+		t.Errorf("opening uncross = %+v", open)
+	}
+	_, _ = book.placeOrder(Ask, Limit, 102, 3, 5, 3, pool, rq)
+	step(ClosingAuction)
+	_, _ = book.placeOrder(Ask, Limit, 101, 4, 6, 4, pool, rq)
+	if cl := step(Closed); cl.Price != 101 || cl.Volume != 6 {
+		t.Errorf("closing uncross = %+v", cl)
+	}
+
+	want := []SessionState{Closed, PreOpen, OpeningAuction, Continuous, ClosingAuction, Closed}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	from := Continuous
+	for i, e := range events {
+		if e.Seq != uint64(i+1) || e.From != from || e.To != want[i] {
+			t.Errorf("event %d = %+v, want %v -> %v", i, e, from, want[i])
+		}
+		from = e.To
+	}
+	if events[3].Uncross.Volume != 4 || events[5].Uncross.Volume != 6 {
+		t.Error("uncross results missing from events")
+	}
+}
+
+func TestSessionRejectsBadTransition(t *testing.T) {
+	book, _, rq := newTestEnv()
+	var n int
+	book.SetSessionSink(SessionSinkFunc(func(SessionEvent) { n++ }))
+	for _, to := range []SessionState{PreOpen, OpeningAuction, Continuous, SessionState(42)} {
+		if _, err := book.SetSession(to, rq); err != ErrSessionTransition {
+			t.Errorf("continuous -> %v: expected ErrSessionTransition, got %v", to, err)
+		}
+	}
+	if n != 0 || book.Session() != Continuous {
+		t.Error("rejected transition changed state or reported an event")
+	}
+}
+
+func TestSessionAcceptanceRules(t *testing.T) {
+	paths := map[SessionState][]SessionState{
+		Continuous:     nil,
+		PreOpen:        {Closed, PreOpen},
+		OpeningAuction: {Closed, PreOpen, OpeningAuction},
+		Halted:         {Halted},
+		ClosingAuction: {ClosingAuction},
+		Closed:         {Closed},
+	}
+	cases := []struct {
+		state             SessionState
+		limit, market     RejectReason
+		amend, cancelable bool
+	}{
+		{Continuous, RejectNone, RejectNone, true, true},
+		{PreOpen, RejectSessionState, RejectSessionState, true, true},
+		{OpeningAuction, RejectNone, RejectAuctionCall, true, true},
+		{Halted, RejectSessionState, RejectSessionState, false, true},
+		{ClosingAuction, RejectNone, RejectAuctionCall, true, true},
+		{Closed, RejectSessionState, RejectSessionState, false, true},
+	}
+	for _, c := range cases {
+		book, pool, rq := newTestEnv()
+		_, _ = book.placeOrder(Bid, Limit, 99, 1, 10, 1, pool, rq)
+		_, _ = book.placeOrder(Bid, Limit, 98, 2, 10, 2, pool, rq)
+		_, _ = book.placeOrder(Ask, Limit, 101, 3, 10, 3, pool, rq)
+		for _, s := range paths[c.state] {
+			if _, err := book.SetSession(s, rq); err != nil {
+				t.Fatalf("%v: %v", s, err)
+			}
+		}
+		if _, r := book.placeOrder(Bid, Limit, 100, 10, 5, 10, pool, rq); r != c.limit {
+			t.Errorf("%v: limit got %v, want %v", c.state, r, c.limit)
+		}
+		if _, r := book.placeOrder(Bid, Market, 0, 11, 5, 11, pool, rq); r != c.market {
+			t.Errorf("%v: market got %v, want %v", c.state, r, c.market)
+		}
+		if _, r := book.Amend(1, 99, 5, 12, rq); (r == RejectNone) != c.amend {
+			t.Errorf("%v: amend got %v", c.state, r)
+		}
+		if r := book.CancelByID(2, rq); (r == RejectNone) != c.cancelable {
+			t.Errorf("%v: cancel got %v", c.state, r)
+		}
+	}
+}
+
+func TestHaltFreezesMatching(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 10, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 99, 2, 10, 2, pool, rq)
+	_, _ = book.submit(OrderSpec{ID: 3, Seq: 3, Side: Bid, Type: Stop, StopPrice: 100, Qty: 2}, pool, rq)
+	var execs int
+	book.SetExecutionSink(ExecutionSinkFunc(func(Execution) { execs++ }))
+
+	if _, err := book.SetSession(Halted, rq); err != nil {
+		t.Fatal(err)
+	}
+	if _, r := book.placeOrder(Bid, IOC, 100, 4, 5, 4, pool, rq); r != RejectSessionState {
+		t.Errorf("expected halted reject, got %v", r)
+	}
+	if r := book.CancelByID(2, rq); r != RejectNone {
+		t.Errorf("cancel during halt: %v", r)
+	}
+	if _, err := book.SetSession(Continuous, rq); err != nil {
+		t.Fatal(err)
+	}
+	if execs != 0 {
+		t.Errorf("%d trades while halted or on resume", execs)
+	}
+	if _, r := book.placeOrder(Bid, IOC, 100, 5, 5, 5, pool, rq); r != RejectNone || execs == 0 {
+		t.Errorf("expected trading to resume (%v)", r)
+	}
+	if book.buyStops.Size() != 0 {
+		t.Error("stop at the resumed last price did not fire")
+	}
+}
+
+// StartAuction and Uncross are transitions too: a halted book with a
+// crossed call in it must not trade on CmdUncross.
+func TestUncrossCommandFollowsSession(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	var execs int
+	book.SetExecutionSink(ExecutionSinkFunc(func(Execution) { execs++ }))
+	if res := book.Apply(Command{Kind: CmdAuction}, pool, rq); res.Err != nil || book.Session() != VolatilityAuction {
+		t.Fatalf("CmdAuction from continuous: %v, now %v", res.Err, book.Session())
+	}
+	_, _ = book.placeOrder(Bid, Limit, 100, 1, 10, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 2, 10, 2, pool, rq)
+	if _, err := book.SetSession(Halted, rq); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range []CommandKind{CmdUncross, CmdAuction} {
+		if res := book.Apply(Command{Kind: kind}, pool, rq); res.Err != ErrSessionTransition {
+			t.Errorf("command %d while halted: expected ErrSessionTransition, got %v", kind, res.Err)
+		}
+	}
+	if execs != 0 || book.Session() != Halted {
+		t.Fatalf("%d executions, session %v: halted book traded", execs, book.Session())
+	}
+
+	_, _ = book.SetSession(OpeningAuction, rq)
+	if res := book.Apply(Command{Kind: CmdUncross}, pool, rq); res.Err != nil || res.Auction.Volume != 10 || execs != 1 {
+		t.Errorf("uncross from the auction: %+v, %d executions", res, execs)
+	}
+	if book.Session() != Continuous || book.InAuction() {
+		t.Errorf("uncross left the book in %v", book.Session())
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+// ---------------- Stop / Stop-limit trigger book ---------------- //
+//
+// Pending stops rest in their own RBTrees keyed by stop price, FIFO per
+// level, so orders triggered at the same price release in sequence order.
+// Buy stops fire when the last trade is at or above the stop price, sell
+// stops when it is at or below. A released stop trades like any incoming
+// order and may move the last price further, cascading more triggers.
+
+func isStop(t OrderType) bool { return t == Stop || t == StopLimit }
+
+// stopTree returns the trigger book for stops of 'side'.
+func (b *OrderBook) stopTree(side Side) *RBTree {
+	if side == Bid {
+		return b.buyStops
+	}
+	return b.sellStops
+}
+
+// releaseStops fires triggered stops until none is left at the last price.
+// Stops wait out an auction call and fire on the uncross price.
+func (b *OrderBook) releaseStops(rq *retireRing) {
+	for !b.calling() {
+		o := b.nextTriggered()
+		if o == nil {
+			return
+		}
+		b.unlink(o.StopPrice, o, o.Side)
+		if o.Type == Stop {
+			o.Type, o.Price = Market, 0 // an amend may have set a limit
+		} else {
+			o.Type = Limit
+		}
+		b.execute(o, rq)
+	}
+}
+
+// nextTriggered returns the oldest order on the first triggered stop level.
+func (b *OrderBook) nextTriggered() *Order {
+	if b.lastTrade == 0 {
+		return nil
+	}
+	if lvl := b.buyStops.MinLevel(); lvl != nil && b.lastTrade >= lvl.Price {
+		return lvl.head
+	}
+	if lvl := b.sellStops.MaxLevel(); lvl != nil && b.lastTrade <= lvl.Price {
+		return lvl.head
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import "testing"
+
+func placeStop(book *OrderBook, side Side, otype OrderType, stop, price int64, id uint64, qty int64, pool *OrderPool, rq *retireRing) (*Order, RejectReason) {
+	return book.submit(OrderSpec{
+		ID: id, Seq: id, Side: side, Type: otype, Price: price, Qty: qty, StopPrice: stop,
+	}, pool, rq)
+}
+
+func TestBuyStopTriggersOnLastTrade(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 101, 2, 5, 2, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 102, 3, 5, 3, pool, rq)
+
+	stop, r := placeStop(book, Bid, Stop, 101, 0, 10, 6, pool, rq)
+	if r != RejectNone || stop.Status != Active {
+		t.Fatalf("expected pending stop, got %v", r)
+	}
+	if book.Bids.Size() != 0 {
+		t.Error("pending stop must not rest in the visible book")
+	}
+
+	// Trade at 100 does not reach the stop
+	_, _ = book.placeOrder(Bid, Limit, 100, 4, 5, 4, pool, rq)
+	if stop.Filled != 0 {
+		t.Fatal("stop fired below its trigger price")
+	}
+
+	// Trade at 101 fires it as a Market order
+	_, _ = book.placeOrder(Bid, Limit, 101, 5, 1, 5, pool, rq)
+	if stop.Filled != 6 || stop.Status != Inactive {
+		t.Errorf("expected stop fully filled as market, got filled=%d", stop.Filled)
+	}
+	if lvl := book.Asks.MinLevel(); lvl == nil || lvl.Price != 102 || lvl.TotalQty != 3 {
+		t.Error("expected market stop to sweep 101 and take 2 at 102")
+	}
+}
+
+func TestStopLimitRestsAfterTrigger(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Bid, Limit, 100, 1, 5, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 99, 2, 5, 2, pool, rq)
+
+	sl, _ := placeStop(book, Ask, StopLimit, 100, 100, 10, 8, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 100, 3, 1, 3, pool, rq) // trade @100
+
+	if sl.Type != Limit || sl.Filled != 4 || sl.Qty != 4 {
+		t.Fatalf("expected limit fill 4 @100, got type=%d filled=%d qty=%d", sl.Type, sl.Filled, sl.Qty)
+	}
+	if lvl := book.Asks.FindLevel(100); lvl == nil || lvl.head != sl {
+		t.Error("expected stop-limit remainder resting @100")
+	}
+}
+
+func TestStopCascade(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	rec := &execRecorder{}
+	book.SetExecutionSink(rec)
+	_, _ = book.placeOrder(Bid, Limit, 99, 1, 1, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 98, 2, 1, 2, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 97, 3, 1, 3, pool, rq)
+
+	s1, _ := placeStop(book, Ask, Stop, 99, 0, 10, 1, pool, rq)
+	s2, _ := placeStop(book, Ask, Stop, 98, 0, 11, 1, pool, rq)
+
+	// One incoming sell trades @99 → s1 sells @98 → s2 sells @97
+	_, _ = book.placeOrder(Ask, Limit, 99, 4, 1, 4, pool, rq)
+
+	if s1.Filled != 1 || s2.Filled != 1 {
+		t.Fatalf("expected cascading stops to fill, got %d %d", s1.Filled, s2.Filled)
+	}
+	if len(rec.execs) != 3 || rec.execs[2].Price != 97 || rec.execs[2].AggressorID != 11 {
+		t.Errorf("unexpected execution sequence %+v", rec.execs)
+	}
+	if book.Bids.Size() != 0 || book.sellStops.Size() != 0 {
+		t.Error("expected bids and trigger book drained")
+	}
+}
+
+func TestStopsSamePriceReleaseInSeqOrder(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	rec := &execRecorder{}
+	book.SetExecutionSink(rec)
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 1, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 105, 2, 10, 2, pool, rq)
+
+	_, _ = placeStop(book, Bid, Stop, 100, 0, 10, 1, pool, rq)
+	_, _ = placeStop(book, Bid, Stop, 100, 0, 11, 1, pool, rq)
+	_, _ = placeStop(book, Bid, Stop, 100, 0, 12, 1, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 100, 3, 1, 3, pool, rq)
+
+	var got []uint64
+	for _, e := range rec.execs[1:] {
+		got = append(got, e.AggressorID)
+	}
+	if len(got) != 3 || got[0] != 10 || got[1] != 11 || got[2] != 12 {
+		t.Errorf("expected stops released in seq order 10,11,12, got %v", got)
+	}
+}
+
+func TestStopImmediateTriggerAndCancel(t *testing.T) {
+	book, pool, rq := newTestEnv()
+	_, _ = book.placeOrder(Ask, Limit, 100, 1, 1, 1, pool, rq)
+	_, _ = book.placeOrder(Ask, Limit, 101, 2, 5, 2, pool, rq)
+	_, _ = book.placeOrder(Bid, Limit, 100, 3, 1, 3, pool, rq) // last = 100
+
+	// Buy stop at 99 is already through → fires on entry
+	s, _ := placeStop(book, Bid, StopLimit, 99, 101, 10, 2, pool, rq)
+	if s.Filled != 2 {
+		t.Errorf("expected immediate trigger, got filled=%d", s.Filled)
+	}
+
+	// A pending stop can be cancelled by ID like any other order
+	p, _ := placeStop(book, Bid, Stop, 150, 0, 11, 2, pool, rq)
+	if r := book.CancelByID(11, rq); r != RejectNone || p.Status != Inactive {
+		t.Errorf("expected pending stop cancelled, got %v", r)
+	}
+	if book.buyStops.Size() != 0 {
+		t.Error("expected trigger book empty after cancel")
+	}
+	if _, r := placeStop(book, Bid, Stop, 0, 0, 12, 2, pool, rq); r != RejectInvalidPrice {
+		t.Errorf("expected invalid stop price reject, got %v", r)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+// STPMode selects what happens when an aggressor meets a resting order
+// from the same account.
+type STPMode uint8
+
+const (
+	STPNone         STPMode = iota // allow self-trades
+	STPCancelNewest                // cancel the aggressor's remainder
+	STPCancelOldest                // cancel the resting order, keep matching
+	STPCancelBoth                  // cancel both
+	STPDecrement                   // decrement both by the smaller size, cancel whichever hits zero
+)
+
+// SelfTradeEvent reports a trade that was prevented and what was cancelled.
+type SelfTradeEvent struct {
+	Account            uint64
+	AggressorID        uint64
+	RestingID          uint64
+	Mode               STPMode
+	Qty                int64 // quantity that would have traded
+	AggressorCancelled bool  // aggressor's remainder cancelled or decremented to zero
+	RestingCancelled   bool  // resting order removed from the book
+}
+
+// SelfTradeSink receives prevented trades on the matcher thread.
+type SelfTradeSink interface {
+	OnSelfTrade(e SelfTradeEvent)
+}
+
+// SelfTradeSinkFunc adapts a plain function to SelfTradeSink.
+type SelfTradeSinkFunc func(e SelfTradeEvent)
+
+func (f SelfTradeSinkFunc) OnSelfTrade(e SelfTradeEvent) { f(e) }
+
+// SetSelfTradePrevention sets the policy applied to orders with an Account.
+func (b *OrderBook) SetSelfTradePrevention(mode STPMode, s SelfTradeSink) {
+	b.stp, b.stpSink = mode, s
+}
+
+// preventSelfTrade applies the STP policy to aggressor o meeting 'rest' at
+// the head of 'lvl'. It reports whether the aggressor was cancelled: the
+// caller then stops matching and retires it with the cancelled quantity
+// left in o.Qty; nothing is reported as filled.
+func (b *OrderBook) preventSelfTrade(o, rest *Order, lvl *PriceLevel, rq *retireRing) bool {
+	restOpen := rest.Qty + rest.hidden
+	e := SelfTradeEvent{
+		Account: o.Account, AggressorID: o.ID, RestingID: rest.ID,
+		Mode: b.stp, Qty: min(o.Qty, restOpen),
+	}
+
+	cancelAggr, cancelRest := false, false
+	switch b.stp {
+	case STPCancelNewest:
+		cancelAggr = true
+	case STPCancelOldest:
+		cancelRest = true
+	case STPCancelBoth:
+		cancelAggr, cancelRest = true, true
+	case STPDecrement:
+		o.Qty -= e.Qty
+		b.reduce(lvl, rest, restOpen-e.Qty)
+		cancelRest = rest.Qty == 0
+	}
+
+	if cancelRest {
+		b.remove(lvl.Price, rest, rq, rest.Side)
+	} else if b.stp == STPDecrement {
+		b.emitL2(rest.Side, lvl.Price, lvl, L2Change)
+	}
+	e.AggressorCancelled = cancelAggr || o.Qty == 0
+	e.RestingCancelled = cancelRest
+	if b.stpSink != nil {
+		b.stpSink.OnSelfTrade(e)
+	}
+	return cancelAggr
+}
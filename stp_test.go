@@ -0,0 +1,146 @@
+package main
+
+import "testing"
+
+func placeFor(book *OrderBook, acct uint64, side Side, otype OrderType, price int64, id uint64, qty int64, pool *OrderPool, rq *retireRing) *Order {
+	o, _ := book.submit(OrderSpec{
+		ID: id, Seq: id, Account: acct, Side: side, Type: otype, Price: price, Qty: qty,
+	}, pool, rq)
+	return o
+}
+
+// stpEnv: account 7 rests ask 5@100 (id 1) ahead of account 8 ask 5@100 (id 2).
+func stpEnv(mode STPMode) (*OrderBook, *OrderPool, *retireRing, *[]SelfTradeEvent, *execRecorder) {
+	book, pool, rq := newTestEnv()
+	var events []SelfTradeEvent
+	rec := &execRecorder{}
+	book.SetExecutionSink(rec)
+	book.SetSelfTradePrevention(mode, SelfTradeSinkFunc(func(e SelfTradeEvent) { events = append(events, e) }))
+	placeFor(book, 7, Ask, Limit, 100, 1, 5, pool, rq)
+	placeFor(book, 8, Ask, Limit, 100, 2, 5, pool, rq)
+	return book, pool, rq, &events, rec
+}
+
+func TestSTPCancelNewest(t *testing.T) {
+	book, pool, rq, events, rec := stpEnv(STPCancelNewest)
+	bid := placeFor(book, 7, Bid, Limit, 100, 3, 8, pool, rq)
+
+	if bid.Status != Inactive || bid.Filled != 0 || bid.Qty != 8 || len(rec.execs) != 0 {
+		t.Error("expected aggressor cancelled without trading, its 8 left in Qty")
+	}
+	if len(*events) != 1 || !(*events)[0].AggressorCancelled || (*events)[0].RestingCancelled {
+		t.Errorf("unexpected events %+v", *events)
+	}
+	if book.Asks.FindLevel(100).TotalQty != 10 || book.Bids.Size() != 0 {
+		t.Error("book must be untouched")
+	}
+}
+
+// An amend that crosses the account's own ask is cancelled like a new order.
+func TestSTPCancelNewestOnAmend(t *testing.T) {
+	book, pool, rq, events, rec := stpEnv(STPCancelNewest)
+	bid := placeFor(book, 7, Bid, Limit, 99, 3, 8, pool, rq)
+	if _, r := book.Amend(3, 100, 8, 4, rq); r != RejectNone {
+		t.Fatal(r)
+	}
+	if len(*events) != 1 || !(*events)[0].AggressorCancelled || len(rec.execs) != 0 {
+		t.Fatalf("unexpected events %+v, execs %+v", *events, rec.execs)
+	}
+	if _, r := book.Lookup(3); r == RejectNone || bid.Qty != 8 {
+		t.Errorf("amended bid still live or lost its quantity (%d)", bid.Qty)
+	}
+	if book.Bids.Size() != 0 || book.Asks.FindLevel(100).TotalQty != 10 {
+		t.Error("book must hold only the original asks")
+	}
+}
+
+func TestSTPCancelOldest(t *testing.T) {
+	book, pool, rq, events, rec := stpEnv(STPCancelOldest)
+	bid := placeFor(book, 7, Bid, Limit, 100, 3, 8, pool, rq)
+
+	if len(*events) != 1 || !(*events)[0].RestingCancelled || (*events)[0].AggressorCancelled {
+		t.Fatalf("unexpected events %+v", *events)
+	}
+	// Resting self order gone, aggressor continues against account 8
+	if len(rec.execs) != 1 || rec.execs[0].RestingID != 2 || rec.execs[0].Qty != 5 {
+		t.Errorf("expected fill against order 2, got %+v", rec.execs)
+	}
+	if bid.Qty != 3 || bid.Status != Active {
+		t.Errorf("expected remainder 3 resting, got %d", bid.Qty)
+	}
+}
+
+func TestSTPCancelBoth(t *testing.T) {
+	book, pool, rq, events, rec := stpEnv(STPCancelBoth)
+	bid := placeFor(book, 7, Bid, Limit, 100, 3, 8, pool, rq)
+
+	e := (*events)[0]
+	if !e.AggressorCancelled || !e.RestingCancelled || e.Qty != 5 {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if bid.Status != Inactive || bid.Qty != 8 || len(rec.execs) != 0 {
+		t.Error("expected no trades and aggressor cancelled, its 8 left in Qty")
+	}
+	if lvl := book.Asks.FindLevel(100); lvl.head.ID != 2 || lvl.TotalQty != 5 {
+		t.Error("expected only the other account's order left")
+	}
+}
+
+func TestSTPDecrementAndCancel(t *testing.T) {
+	book, pool, rq, events, rec := stpEnv(STPDecrement)
+
+	// Smaller aggressor: decremented to zero, resting order keeps 2
+	small := placeFor(book, 7, Bid, Limit, 100, 3, 3, pool, rq)
+	if small.Status != Inactive || small.Filled != 0 {
+		t.Error("expected aggressor decremented away")
+	}
+	head := book.Asks.FindLevel(100).head
+	if head.ID != 1 || head.Qty != 2 {
+		t.Errorf("expected resting self order decremented to 2, got id=%d qty=%d", head.ID, head.Qty)
+	}
+
+	// Larger aggressor: resting self order cancelled, rest trades with account 8
+	big := placeFor(book, 7, Bid, Limit, 100, 4, 6, pool, rq)
+	if len(*events) != 2 || !(*events)[1].RestingCancelled || (*events)[1].Qty != 2 {
+		t.Fatalf("unexpected events %+v", *events)
+	}
+	if len(rec.execs) != 1 || rec.execs[0].RestingID != 2 || rec.execs[0].Qty != 4 {
+		t.Errorf("expected 4 traded with order 2, got %+v", rec.execs)
+	}
+	if big.Qty != 0 || big.Filled != 4 {
+		t.Errorf("expected aggressor done with 4 filled, got qty=%d filled=%d", big.Qty, big.Filled)
+	}
+}
+
+func TestSTPIgnoresAnonymousAndOtherAccounts(t *testing.T) {
+	book, pool, rq, events, rec := stpEnv(STPCancelNewest)
+	_, _ = book.placeOrder(Bid, Limit, 100, 3, 5, 3, pool, rq) // account 0
+	placeFor(book, 9, Bid, Limit, 100, 4, 5, pool, rq)
+
+	if len(*events) != 0 || len(rec.execs) != 2 {
+		t.Errorf("expected plain trades, got %d events %d execs", len(*events), len(rec.execs))
+	}
+}
+
+// An FOK must not count its own resting orders when STP would stop it there.
+func TestSTPFOKStaysAllOrNothing(t *testing.T) {
+	for _, mode := range []STPMode{STPCancelNewest, STPCancelBoth, STPDecrement} {
+		book, pool, rq, events, rec := stpEnv(mode)
+		fok := placeFor(book, 7, Bid, FOK, 100, 3, 10, pool, rq)
+		if fok.Filled != 0 || len(rec.execs) != 0 || len(*events) != 0 {
+			t.Errorf("mode %d: FOK filled %d with %d STP events, want a clean kill", mode, fok.Filled, len(*events))
+		}
+		if book.Asks.FindLevel(100).TotalQty != 10 {
+			t.Errorf("mode %d: book must be untouched", mode)
+		}
+	}
+
+	// STPCancelOldest removes the own order, so only account 8's 5 counts.
+	book, pool, rq, _, _ := stpEnv(STPCancelOldest)
+	if fok := placeFor(book, 7, Bid, FOK, 100, 3, 10, pool, rq); fok.Filled != 0 {
+		t.Errorf("FOK filled %d against 5 of other liquidity", fok.Filled)
+	}
+	if fok := placeFor(book, 7, Bid, FOK, 100, 4, 5, pool, rq); fok.Filled != 5 {
+		t.Errorf("FOK for 5 filled %d", fok.Filled)
+	}
+}